@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/adapters/importer"
+	v1 "github.com/Shurubtsov/lamoda-test-task/internal/controller/http/v1"
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+)
+
+var errImportUsage = errors.New("usage: import [-format csv|ndjson] <file|->")
+
+// runImport загружает товары из файла или stdin и печатает отчёт в stdout.
+func runImport(ctx context.Context, importUC v1.ImportUsecase, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "file format: csv or ndjson, detected by extension when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errImportUsage
+	}
+	path := flags.Arg(0)
+
+	if *format == "" {
+		switch filepath.Ext(path) {
+		case ".csv":
+			*format = string(models.ImportCSV)
+		case ".ndjson", ".jsonl":
+			*format = string(models.ImportNDJSON)
+		}
+	}
+	importFormat, err := models.ParseImportFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	reader, err := importer.NewReader(input, importFormat)
+	if err != nil {
+		return err
+	}
+
+	report, err := importUC.Import(ctx, reader)
+	if report != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if encErr := encoder.Encode(report); encErr != nil {
+			return fmt.Errorf("write report: %w", encErr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return models.ErrRowsNotImported
+	}
+
+	return nil
+}
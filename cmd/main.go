@@ -2,17 +2,29 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/Shurubtsov/lamoda-test-task/internal/adapters/db"
+	"github.com/Shurubtsov/lamoda-test-task/internal/adapters/notifier"
+	"github.com/Shurubtsov/lamoda-test-task/internal/adapters/publisher"
+	"github.com/Shurubtsov/lamoda-test-task/internal/adapters/webhook"
 	"github.com/Shurubtsov/lamoda-test-task/internal/config"
 	v1 "github.com/Shurubtsov/lamoda-test-task/internal/controller/http/v1"
 	"github.com/Shurubtsov/lamoda-test-task/internal/controller/middleware"
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
 	"github.com/Shurubtsov/lamoda-test-task/internal/domain/service"
 	"github.com/Shurubtsov/lamoda-test-task/internal/domain/usecase"
 	"github.com/Shurubtsov/lamoda-test-task/pkg/client/postgresql"
 	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/metrics"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/tracing"
 )
 
 func main() {
@@ -20,7 +32,15 @@ func main() {
 	logger.Info().Msg("initialize dependencies")
 	cfg := config.GetConfig()
 
-	pgClient, err := postgresql.NewClient(context.TODO(), 5)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Init(ctx, cfg.Service.TraceExporter, cfg.Service.TraceFile, cfg.Service.TraceOTLPEndpoint, cfg.Service.TraceSampleRatio)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed init tracing")
+	}
+
+	pgClient, err := postgresql.NewClient(ctx, 5)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("failed create new pgx client")
 	}
@@ -32,25 +52,202 @@ func main() {
 
 	repo := db.New(pgClient)
 
-	storageService := service.NewStorageService(repo)
-	productService := service.NewProductService(repo)
+	strategy, err := models.ParseAllocationStrategy(cfg.Service.AllocationStrategy)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("unknown allocation strategy")
+	}
+	if strategy == "" {
+		strategy = models.AllocationSingle
+	}
+	alertNotifier, err := notifier.New(cfg.Service.AlertNotifier, cfg.Service.AlertFile, cfg.Service.AlertWebhookURL)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed create alert notifier")
+	}
+	alerting := usecase.NewAlerting(repo, alertNotifier, cfg.Service.AlertInterval)
 
-	reservationUC := usecase.NewReservation(storageService, productService, repo)
+	storageService := service.NewStorageService(repo, strategy)
+	productService := service.NewProductService(repo, alerting)
+	dispatcher := usecase.NewDispatcher(
+		repo,
+		webhook.NewSender(cfg.Service.WebhookTimeout),
+		cfg.Service.WebhookInterval,
+		cfg.Service.WebhookBackoff,
+		cfg.Service.WebhookMaxAttempts,
+	)
 
-	middleware := middleware.New()
-	server := v1.NewServer(reservationUC, productService, productService, middleware.ProductInUse)
+	importUC := usecase.NewImport(repo, alerting, cfg.Service.ImportBatchSize)
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		err := runImport(ctx, importUC, os.Args[2:])
+		shutdownTracing(context.Background())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("import failed")
+		}
+		return
+	}
+
+	reservationUC := usecase.NewReservation(storageService, productService, repo, alerting, cfg.Service.ReservationTTL)
+
+	expiration := usecase.NewExpiration(repo, alerting, cfg.Service.ExpirationInterval)
+
+	outboxPublisher, err := publisher.New(
+		cfg.Service.OutboxPublisher,
+		cfg.Service.OutboxFile,
+		cfg.Service.OutboxKafkaURL,
+		cfg.Service.OutboxKafkaTopic,
+	)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed create outbox publisher")
+	}
+	relay := usecase.NewRelay(repo, outboxPublisher, cfg.Service.OutboxInterval, cfg.Service.OutboxBatchSize)
+
+	idempotency := middleware.NewIdempotency(repo, cfg.Service.IdempotencyRetention, cfg.Service.IdempotencyLease)
+
+	// фоновые обработчики останавливаются после завершения запросов,
+	// которые могут запрашивать у них внеочередную проверку остатков
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	runWorker := func(run func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workersCtx)
+		}()
+	}
+	runWorker(expiration.Run)
+	runWorker(alerting.Run)
+	runWorker(dispatcher.Run)
+	runWorker(relay.Run)
+	runWorker(func(ctx context.Context) { idempotency.Run(ctx, cfg.Service.IdempotencyCleanupInterval) })
+
+	stockUC := usecase.NewStock(productService, repo, alerting)
+	inboundUC := usecase.NewInbound(productService, repo, alerting)
+	transferUC := usecase.NewTransfer(productService, repo, alerting)
+	countUC := usecase.NewCount(productService, repo, alerting)
+	alertUC := usecase.NewAlert(repo, alerting)
+	webhookUC := usecase.NewWebhook(repo)
+	healthUC := usecase.NewHealth(repo, cfg.Service.MigrationVersion)
+
+	server := v1.NewServer(reservationUC, productService, stockUC)
+	storageServer := v1.NewStorageServer(storageService)
+	productServer := v1.NewProductServer(productService, importUC)
+	inboundServer := v1.NewInboundServer(inboundUC)
+	transferServer := v1.NewTransferServer(transferUC)
+	countServer := v1.NewCountServer(countUC)
+	alertServer := v1.NewAlertServer(alertUC)
+	webhookServer := v1.NewWebhookServer(webhookUC)
+	healthServer := v1.NewHealthServer(healthUC)
+
+	if err := metrics.RegisterPool(pgClient); err != nil {
+		logger.Fatal().Err(err).Msg("failed register pool metrics")
+	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/product/reservation", middleware.SyncProducts(server.ReservationHandler))
-	mux.HandleFunc("/product/exemption", middleware.SyncProducts(server.ExemptionHandler))
-	mux.HandleFunc("/storage/products", server.ReceivingProductsHandler)
+	handle := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, middleware.Instrument(pattern, middleware.RequestLog(pattern, handler)))
+	}
+	// пробы и сбор метрик вызываются постоянно, поэтому не пишутся в журнал запросов, трассы и метрики HTTP
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.HandleFunc("GET /healthz", healthServer.LivenessHandler)
+	mux.HandleFunc("GET /readyz", healthServer.ReadinessHandler)
+	handle("GET /status", healthServer.StatusHandler)
+	handle("/product/reservation", idempotency.Idempotent(server.ReservationHandler))
+	handle("/product/exemption", idempotency.Idempotent(server.ExemptionHandler))
+	handle("/storage/products", server.ReceivingProductsHandler)
+	handle("POST /products", productServer.CreateProductHandler)
+	handle("POST /products/import", productServer.ImportProductsHandler)
+	handle("GET /products", productServer.GetProductsHandler)
+	handle("GET /products/{code}", productServer.GetProductHandler)
+	handle("PATCH /products/{code}", productServer.UpdateProductHandler)
+	handle("DELETE /products/{code}", productServer.DeleteProductHandler)
+	handle("GET /products/{code}/movements", productServer.GetMovementsHandler)
+	handle("GET /products/{code}/thresholds", alertServer.GetThresholdsHandler)
+	handle("PUT /products/{code}/thresholds", alertServer.SetThresholdHandler)
+	handle("DELETE /products/{code}/thresholds", alertServer.DeleteThresholdHandler)
+	handle("GET /alerts", alertServer.GetAlertsHandler)
+	handle("POST /webhooks", webhookServer.CreateWebhookHandler)
+	handle("GET /webhooks", webhookServer.GetWebhooksHandler)
+	handle("GET /webhooks/dead-letters", webhookServer.GetDeadLettersHandler)
+	handle("GET /webhooks/{id}", webhookServer.GetWebhookHandler)
+	handle("DELETE /webhooks/{id}", webhookServer.DeleteWebhookHandler)
+	handle("GET /webhooks/{id}/deliveries", webhookServer.GetDeliveriesHandler)
+	handle("POST /webhooks/deliveries/{id}/retry", webhookServer.RetryDeliveryHandler)
+	handle("POST /storages", storageServer.CreateStorageHandler)
+	handle("GET /storages", storageServer.GetStoragesHandler)
+	handle("GET /storages/{id}", storageServer.GetStorageHandler)
+	handle("PATCH /storages/{id}", storageServer.UpdateStorageHandler)
+	handle("DELETE /storages/{id}", storageServer.DeleteStorageHandler)
+	handle("PUT /storages/{id}/stock", server.SetStockHandler)
+	handle("GET /storages/{id}/export", server.ExportStockHandler)
+	handle("POST /inbound", idempotency.Idempotent(inboundServer.RegisterInboundHandler))
+	handle("GET /inbound/{id}", inboundServer.GetInboundHandler)
+	handle("POST /inbound/{id}/receive", idempotency.Idempotent(inboundServer.ReceiveInboundHandler))
+	handle("POST /transfers", idempotency.Idempotent(transferServer.CreateTransferHandler))
+	handle("GET /transfers/{id}", transferServer.GetTransferHandler)
+	handle("POST /transfers/{id}/complete", transferServer.CompleteTransferHandler)
+	handle("POST /transfers/{id}/cancel", transferServer.CancelTransferHandler)
+	handle("POST /counts", idempotency.Idempotent(countServer.OpenCountHandler))
+	handle("GET /counts/{id}", countServer.GetCountHandler)
+	handle("PUT /counts/{id}/lines", countServer.SubmitCountHandler)
+	handle("POST /counts/{id}/commit", countServer.CommitCountHandler)
+	handle("POST /counts/{id}/cancel", countServer.CancelCountHandler)
+	handle("GET /reservations/{id}", server.GetReservationHandler)
+	handle("DELETE /reservations/{id}", server.ReleaseReservationHandler)
+	handle("POST /reservations/{id}/fulfil", server.FulfilReservationHandler)
+	handle("POST /reservations/{id}/extend", server.ExtendReservationHandler)
 
+	// контекст запросов отменяется, если они не завершились за время ожидания остановки
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
 	srv := http.Server{
-		Addr:    cfg.Service.Address,
-		Handler: mux,
+		Addr:        cfg.Service.Address,
+		Handler:     mux,
+		BaseContext: func(net.Listener) context.Context { return requestsCtx },
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	logger.Info().Str("address", cfg.Service.Address).Msg("http server started")
+
+	var failed bool
+	select {
+	case <-ctx.Done():
+		logger.Info().Dur("timeout", cfg.Service.ShutdownTimeout).Msg("shutdown signal received, draining requests")
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Error().Err(err).Msg("http server failed")
+			failed = true
+		}
+	}
+	// повторный сигнал завершает процесс сразу
+	stop()
+
+	// оркестратор должен увидеть неготовность и убрать сервис из балансировки до закрытия соединений
+	healthUC.Shutdown()
+	if !failed && cfg.Service.ShutdownDelay > 0 {
+		time.Sleep(cfg.Service.ShutdownDelay)
+	}
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.Service.ShutdownTimeout)
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Warn().Err(err).Msg("requests were not drained in time, cancelling them")
 	}
+	cancelShutdown()
+	cancelRequests()
+
+	stopWorkers()
+	workers.Wait()
 
-	if err := srv.ListenAndServe(); err != nil {
-		log.Fatalln(err)
+	// Close дожидается, пока прерванные запросы вернут соединения в пул
+	pgClient.Close()
+
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), time.Second*5)
+	if err := shutdownTracing(tracingCtx); err != nil {
+		logger.Warn().Err(err).Msg("can't export remaining spans")
+	}
+	cancelTracing()
+	logger.Info().Msg("service stopped")
+	if failed {
+		os.Exit(1)
 	}
 }
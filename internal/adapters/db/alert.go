@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/jackc/pgx/v5"
+)
+
+// evaluateAlertsQ сравнивает свободный остаток с действующими порогами: открывает предупреждения
+// по товарам ниже порога и закрывает открытые предупреждения, для которых это больше не так.
+// Порог без склада действует на складах, где есть строка остатка товара, а если товара нет ни на одном складе -
+// на всех доступных складах, чтобы товар без остатков тоже давал предупреждение.
+// Возвращает открытые и закрытые этим проходом предупреждения.
+const evaluateAlertsQ = `WITH effective AS (
+		SELECT DISTINCT ON (st.storage_id, t.product_id) st.storage_id, t.product_id, t.threshold
+		FROM stock_thresholds t
+		JOIN storages st ON st.storage_id = COALESCE(t.storage_id, st.storage_id)
+		LEFT JOIN stocks q ON q.storage_id = st.storage_id AND q.product_id = t.product_id
+		WHERE st.storage_aviable AND (t.storage_id IS NOT NULL OR q.product_id IS NOT NULL
+			OR NOT EXISTS (SELECT 1 FROM stocks n WHERE n.product_id = t.product_id))
+		ORDER BY st.storage_id, t.product_id, t.storage_id NULLS LAST
+	), levels AS (
+		SELECT s.storage_id, s.product_id, s.threshold,
+			GREATEST(COALESCE(q.quantity, 0) - ` + reservedSubQ + `, 0) AS available
+		FROM effective s
+		LEFT JOIN stocks q ON q.storage_id = s.storage_id AND q.product_id = s.product_id
+	), below AS (
+		SELECT storage_id, product_id, threshold, available FROM levels WHERE available < threshold
+	), resolved AS (
+		UPDATE stock_alerts a SET alert_status = 'resolved', resolved_at = now()
+		WHERE a.alert_status = 'open' AND NOT EXISTS (
+			SELECT 1 FROM below b WHERE b.storage_id = a.storage_id AND b.product_id = a.product_id
+		)
+		RETURNING a.alert_id, a.storage_id, a.product_id, a.threshold, a.available, a.alert_status, a.created_at, a.resolved_at
+	), raised AS (
+		INSERT INTO stock_alerts (storage_id, product_id, threshold, available)
+		SELECT storage_id, product_id, threshold, available FROM below
+		ON CONFLICT (storage_id, product_id) WHERE alert_status = 'open' DO NOTHING
+		RETURNING alert_id, storage_id, product_id, threshold, available, alert_status, created_at, resolved_at
+	)
+	SELECT a.alert_id, a.storage_id, a.product_id, p.product_code, a.threshold, a.available,
+		a.alert_status, a.created_at, a.resolved_at
+	FROM (SELECT * FROM raised UNION ALL SELECT * FROM resolved) a
+	JOIN products p ON p.product_id = a.product_id
+	ORDER BY a.alert_id`
+
+// SetThreshold устанавливает порог товара на складе или, если склад не указан, на всех складах.
+func (r *repository) SetThreshold(ctx context.Context, code string, threshold models.Threshold) (*models.Threshold, error) {
+	ctx, end := startQuery(ctx, "SetThreshold")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start SetThreshold")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	if threshold.StorageID != nil {
+		if err := storageExists(ctx, r.client, *threshold.StorageID); err != nil {
+			return nil, err
+		}
+	}
+
+	// для порогов со складом и без него разные уникальные индексы
+	conflict := `ON CONFLICT (product_id) WHERE storage_id IS NULL`
+	if threshold.StorageID != nil {
+		conflict = `ON CONFLICT (product_id, storage_id) WHERE storage_id IS NOT NULL`
+	}
+	q := `INSERT INTO stock_thresholds (product_id, storage_id, threshold)
+		SELECT product_id, $2::int, $3::int FROM products WHERE product_code = $1
+		` + conflict + ` DO UPDATE SET threshold = EXCLUDED.threshold, updated_at = now()
+		RETURNING product_id, storage_id, threshold, updated_at`
+	updated := &models.Threshold{Code: code}
+	if err := r.client.QueryRow(ctx, q, code, threshold.StorageID, threshold.Threshold).Scan(
+		&updated.ProductID, &updated.StorageID, &updated.Threshold, &updated.UpdatedAt,
+	); err != nil {
+		return nil, productError(err)
+	}
+
+	return updated, nil
+}
+
+// FindThresholds возвращает пороги товара, общий порог идёт первым.
+func (r *repository) FindThresholds(ctx context.Context, code string) ([]models.Threshold, error) {
+	ctx, end := startQuery(ctx, "FindThresholds")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindThresholds")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	var productID uint
+	if err := r.client.QueryRow(ctx, `SELECT product_id FROM products WHERE product_code = $1`, code).Scan(&productID); err != nil {
+		return nil, productError(err)
+	}
+
+	q := `SELECT product_id, $1::text, storage_id, threshold, updated_at FROM stock_thresholds
+		WHERE product_id = $2 ORDER BY storage_id NULLS FIRST`
+	rows, err := r.client.Query(ctx, q, code, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Threshold, error) {
+		var t models.Threshold
+		err := row.Scan(&t.ProductID, &t.Code, &t.StorageID, &t.Threshold, &t.UpdatedAt)
+		return t, err
+	})
+}
+
+// DeleteThreshold удаляет порог товара на складе или общий порог, если склад не указан.
+func (r *repository) DeleteThreshold(ctx context.Context, code string, storageID *uint) error {
+	ctx, end := startQuery(ctx, "DeleteThreshold")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start DeleteThreshold")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `DELETE FROM stock_thresholds t USING products p
+		WHERE p.product_id = t.product_id AND p.product_code = $1 AND t.storage_id IS NOT DISTINCT FROM $2::int`
+	tag, err := r.client.Exec(ctx, q, code, storageID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrThresholdNotFound
+	}
+
+	return nil
+}
+
+// FindAlerts возвращает предупреждения о низком остатке, новые идут первыми.
+func (r *repository) FindAlerts(ctx context.Context, filter models.AlertFilter) ([]models.StockAlert, error) {
+	ctx, end := startQuery(ctx, "FindAlerts")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindAlerts")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `SELECT a.alert_id, a.storage_id, a.product_id, p.product_code, a.threshold, a.available,
+			a.alert_status, a.created_at, a.resolved_at
+		FROM stock_alerts a JOIN products p ON p.product_id = a.product_id
+		WHERE (@status = '' OR a.alert_status = @status)
+			AND (@storageID::int = 0 OR a.storage_id = @storageID)
+			AND (@code = '' OR p.product_code = @code)
+		ORDER BY a.alert_id DESC LIMIT @limit OFFSET @offset`
+	rows, err := r.client.Query(ctx, q, pgx.NamedArgs{
+		"status":    string(filter.Status),
+		"storageID": filter.StorageID,
+		"code":      filter.Code,
+		"limit":     filter.Limit,
+		"offset":    filter.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, scanAlert)
+}
+
+// EvaluateAlerts пересматривает предупреждения по текущим остаткам и резервам.
+func (r *repository) EvaluateAlerts(ctx context.Context) ([]models.StockAlert, error) {
+	ctx, end := startQuery(ctx, "EvaluateAlerts")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start EvaluateAlerts")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+
+	rows, err := r.client.Query(ctx, evaluateAlertsQ)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, scanAlert)
+}
+
+func scanAlert(row pgx.CollectableRow) (models.StockAlert, error) {
+	var a models.StockAlert
+	err := row.Scan(&a.ID, &a.StorageID, &a.ProductID, &a.Code, &a.Threshold, &a.Available,
+		&a.Status, &a.CreatedAt, &a.ResolvedAt)
+	return a, err
+}
@@ -0,0 +1,283 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/jackc/pgx/v5"
+)
+
+func (r *repository) CreateCount(ctx context.Context, storageID uint) (*models.InventoryCount, error) {
+	ctx, end := startQuery(ctx, "CreateCount")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CreateCount")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	if err := storageExists(ctx, r.client, storageID); err != nil {
+		return nil, err
+	}
+
+	var countID uint64
+	q := `INSERT INTO inventory_counts (storage_id) VALUES ($1) RETURNING count_id`
+	if err := r.client.QueryRow(ctx, q, storageID).Scan(&countID); err != nil {
+		return nil, err
+	}
+
+	return r.FindCountViaID(ctx, countID)
+}
+
+// FindCountViaID возвращает пересчёт с расхождениями: для открытого пересчёта относительно
+// текущих остатков склада, для применённого - относительно остатков на момент применения.
+func (r *repository) FindCountViaID(ctx context.Context, countID uint64) (*models.InventoryCount, error) {
+	ctx, end := startQuery(ctx, "FindCountViaID")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindCountViaID")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	count := &models.InventoryCount{}
+	q := `SELECT count_id, storage_id, count_status, created_at, updated_at, committed_at
+		FROM inventory_counts WHERE count_id = $1`
+	if err := r.client.QueryRow(ctx, q, countID).Scan(
+		&count.ID, &count.StorageID, &count.Status, &count.CreatedAt, &count.UpdatedAt, &count.CommittedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrCountNotFound
+		}
+		return nil, err
+	}
+
+	linesQ := `SELECT l.product_id, p.product_code, COALESCE(p.product_name, ''), l.counted_quantity,
+			COALESCE(l.system_quantity, s.quantity, 0), ` + reservedSubQ + `
+		FROM inventory_count_lines l
+		JOIN products p ON p.product_id = l.product_id
+		LEFT JOIN stocks s ON s.storage_id = $2 AND s.product_id = l.product_id
+		WHERE l.count_id = $1 ORDER BY p.product_code`
+	rows, err := r.client.Query(ctx, linesQ, countID, count.StorageID)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.CountLine, error) {
+		var line models.CountLine
+		var onHand, reserved uint
+		if err := row.Scan(&line.ProductID, &line.Code, &line.Name, &line.Counted, &onHand, &reserved); err != nil {
+			return line, err
+		}
+		line.SetSystem(onHand, reserved)
+		// конфликты имеют смысл только до применения пересчёта
+		line.Conflict = line.Conflict && count.Status == models.CountOpen
+		return line, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	count.Lines = lines
+
+	return count, nil
+}
+
+// SetCountLines сохраняет фактическое количество товаров, повторная отправка товара заменяет прежнее значение.
+func (r *repository) SetCountLines(ctx context.Context, countID uint64, lines []models.CountLine) (*models.InventoryCount, error) {
+	ctx, end := startQuery(ctx, "SetCountLines")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start SetCountLines")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockCount(ctx, tx, countID); err != nil {
+		return nil, err
+	}
+
+	q := `INSERT INTO inventory_count_lines (count_id, product_id, counted_quantity) VALUES ($1, $2, $3)
+		ON CONFLICT (count_id, product_id) DO UPDATE
+		SET counted_quantity = EXCLUDED.counted_quantity, counted_at = now()`
+	batch := &pgx.Batch{}
+	for _, line := range lines {
+		batch.Queue(q, countID, line.ProductID, *line.Counted)
+	}
+	batch.Queue(`UPDATE inventory_counts SET updated_at = now() WHERE count_id = $1`, countID)
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return r.FindCountViaID(ctx, countID)
+}
+
+// CommitCount устанавливает остатки склада равными фактическому количеству и записывает расхождения
+// в журнал движения. Если фактическое количество какого-либо товара меньше его активных резервов,
+// пересчёт не применяется и возвращается CountConflictError.
+func (r *repository) CommitCount(ctx context.Context, countID uint64) (*models.InventoryCount, error) {
+	ctx, end := startQuery(ctx, "CommitCount")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CommitCount")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	storageID, err := lockCount(ctx, tx, countID)
+	if err != nil {
+		return nil, err
+	}
+
+	linesQ := `SELECT l.product_id, p.product_code, l.counted_quantity
+		FROM inventory_count_lines l JOIN products p ON p.product_id = l.product_id
+		WHERE l.count_id = $1 ORDER BY l.product_id`
+	rows, err := tx.Query(ctx, linesQ, countID)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.CountLine, error) {
+		line := models.CountLine{Counted: new(uint)}
+		err := row.Scan(&line.ProductID, &line.Code, line.Counted)
+		return line, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, models.ErrCountLinesEmpty
+	}
+
+	// товары блокируются, чтобы резервы не изменились до применения пересчёта
+	products := make([]models.Item, 0, len(lines))
+	productIDs := make([]uint, 0, len(lines))
+	for _, line := range lines {
+		products = append(products, models.Item{Product: models.Product{ID: line.ProductID, Code: line.Code}})
+		productIDs = append(productIDs, line.ProductID)
+	}
+	if err := lockProducts(ctx, tx, "count", products); err != nil {
+		return nil, err
+	}
+	onHand, err := lockStocks(ctx, tx, storageID, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	reservedQ := `SELECT i.product_id, SUM(i.quantity)
+		FROM reservation_items i JOIN reservations r ON r.reservation_id = i.reservation_id
+		WHERE i.storage_id = $1 AND i.product_id = ANY($2) AND r.reservation_status = 'active'
+		GROUP BY i.product_id`
+	rows, err = tx.Query(ctx, reservedQ, storageID, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	reserved := make(map[uint]uint, len(productIDs))
+	var productID, quantity uint
+	if _, err := pgx.ForEachRow(rows, []any{&productID, &quantity}, func() error {
+		reserved[productID] = quantity
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	conflicts := make([]models.CountLine, 0)
+	for i := range lines {
+		lines[i].SetSystem(onHand[lines[i].ProductID], reserved[lines[i].ProductID])
+		if lines[i].Conflict {
+			conflicts = append(conflicts, lines[i])
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, &models.CountConflictError{Lines: conflicts}
+	}
+
+	systemQ := `UPDATE inventory_count_lines SET system_quantity = $3 WHERE count_id = $1 AND product_id = $2`
+	stockQ := `INSERT INTO stocks (storage_id, product_id, quantity) VALUES ($1, $2, $3)
+		ON CONFLICT (storage_id, product_id) DO UPDATE SET quantity = EXCLUDED.quantity`
+	batch := &pgx.Batch{}
+	movements := make([]models.Movement, 0, len(lines))
+	for _, line := range lines {
+		batch.Queue(systemQ, countID, line.ProductID, line.OnHand)
+		if line.Variance == 0 {
+			continue
+		}
+		batch.Queue(stockQ, storageID, line.ProductID, *line.Counted)
+		movements = append(movements, models.Movement{
+			Type:         models.MovementAdjustment,
+			StorageID:    storageID,
+			ProductID:    line.ProductID,
+			OnHandChange: line.Variance,
+			CountID:      &countID,
+		})
+	}
+	batch.Queue(`UPDATE inventory_counts SET count_status = 'committed', updated_at = now(), committed_at = now()
+		WHERE count_id = $1`, countID)
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return nil, err
+	}
+	if err := recordMovements(ctx, tx, movements); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return r.FindCountViaID(ctx, countID)
+}
+
+func (r *repository) CancelCount(ctx context.Context, countID uint64) (*models.InventoryCount, error) {
+	ctx, end := startQuery(ctx, "CancelCount")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CancelCount")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockCount(ctx, tx, countID); err != nil {
+		return nil, err
+	}
+	q := `UPDATE inventory_counts SET count_status = 'cancelled', updated_at = now() WHERE count_id = $1`
+	if _, err := tx.Exec(ctx, q, countID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return r.FindCountViaID(ctx, countID)
+}
+
+// lockCount блокирует открытый пересчёт до конца транзакции и возвращает склад пересчёта.
+func lockCount(ctx context.Context, tx pgx.Tx, countID uint64) (uint, error) {
+	var storageID uint
+	var status models.CountStatus
+	q := `SELECT storage_id, count_status FROM inventory_counts WHERE count_id = $1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, q, countID).Scan(&storageID, &status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, models.ErrCountNotFound
+		}
+		return 0, err
+	}
+	if status != models.CountOpen {
+		return 0, models.ErrCountNotOpen
+	}
+	return storageID, nil
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Ping проверяет, что бд отвечает на запросы.
+func (r *repository) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*2)
+	defer cancel()
+	return r.client.Ping(ctx)
+}
+
+// FindMigrationState возвращает версию схемы из таблицы golang-migrate.
+func (r *repository) FindMigrationState(ctx context.Context) (*models.MigrationState, error) {
+	ctx, end := startQuery(ctx, "FindMigrationState")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindMigrationState")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*2)
+	defer cancel()
+
+	state := &models.MigrationState{}
+	q := `SELECT version, dirty FROM schema_migrations LIMIT 1`
+	if err := r.client.QueryRow(ctx, q).Scan(&state.Version, &state.Dirty); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.Is(err, pgx.ErrNoRows) || errors.As(err, &pgErr) && pgErr.Code == "42P01" {
+			return nil, models.ErrMigrationsNotApplied
+		}
+		return nil, err
+	}
+	return state, nil
+}
+
+// PoolStats возвращает состояние пула соединений.
+func (r *repository) PoolStats() models.PoolStats {
+	stat := r.client.Stat()
+	return models.PoolStats{
+		MaxConns:                stat.MaxConns(),
+		TotalConns:              stat.TotalConns(),
+		AcquiredConns:           stat.AcquiredConns(),
+		IdleConns:               stat.IdleConns(),
+		ConstructingConns:       stat.ConstructingConns(),
+		AcquireCount:            stat.AcquireCount(),
+		AcquireDuration:         stat.AcquireDuration(),
+		EmptyAcquireCount:       stat.EmptyAcquireCount(),
+		CanceledAcquireCount:    stat.CanceledAcquireCount(),
+		NewConnsCount:           stat.NewConnsCount(),
+		MaxLifetimeDestroyCount: stat.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     stat.MaxIdleDestroyCount(),
+	}
+}
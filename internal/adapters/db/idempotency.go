@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/jackc/pgx/v5"
+)
+
+// AcquireIdempotencyKey занимает ключ за текущим запросом на lease и возвращает токен захвата.
+// Если ключ уже занят и его срок хранения не истёк, возвращается пустой токен и сохранённый ранее ответ.
+// Устаревшие ключи перезанимаются, как и незавершённые ключи с истёкшим lease, если запрос совпадает.
+func (r *repository) AcquireIdempotencyKey(ctx context.Context, key, requestHash string, retention, lease time.Duration) (string, *models.IdempotentResponse, error) {
+	ctx, end := startQuery(ctx, "AcquireIdempotencyKey")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start AcquireIdempotencyKey")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	acquireQ := `INSERT INTO idempotency_keys (idempotency_key, request_hash, lease_token, locked_until)
+		VALUES ($1, $2, gen_random_uuid(), now() + $4::bigint * interval '1 millisecond')
+		ON CONFLICT (idempotency_key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, lease_token = EXCLUDED.lease_token, locked_until = EXCLUDED.locked_until,
+			status_code = NULL, response_headers = NULL, response_body = NULL, created_at = now(), completed_at = NULL
+		WHERE idempotency_keys.created_at < now() - $3::bigint * interval '1 millisecond'
+			OR (idempotency_keys.completed_at IS NULL AND idempotency_keys.locked_until < now()
+				AND idempotency_keys.request_hash = EXCLUDED.request_hash)
+		RETURNING lease_token::text`
+	var token string
+	err := r.client.QueryRow(ctx, acquireQ, key, requestHash, retention.Milliseconds(), lease.Milliseconds()).Scan(&token)
+	if err == nil {
+		return token, nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, err
+	}
+
+	stored := &models.IdempotentResponse{Key: key}
+	q := `SELECT request_hash, COALESCE(status_code, 0), COALESCE(response_headers, '{}'), response_body, completed_at IS NOT NULL
+		FROM idempotency_keys WHERE idempotency_key = $1`
+	if err := r.client.QueryRow(ctx, q, key).Scan(
+		&stored.RequestHash, &stored.StatusCode, &stored.Headers, &stored.Body, &stored.Completed,
+	); err != nil {
+		return "", nil, err
+	}
+
+	return "", stored, nil
+}
+
+// SaveIdempotentResponse сохраняет ответ, если ключ всё ещё занят запросом с response.LeaseToken.
+func (r *repository) SaveIdempotentResponse(ctx context.Context, response models.IdempotentResponse) error {
+	ctx, end := startQuery(ctx, "SaveIdempotentResponse")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start SaveIdempotentResponse")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `UPDATE idempotency_keys SET status_code = $2, response_headers = $3::jsonb, response_body = $4, completed_at = now()
+		WHERE idempotency_key = $1 AND lease_token = $5::uuid AND completed_at IS NULL`
+	_, err := r.client.Exec(ctx, q, response.Key, response.StatusCode, response.Headers, response.Body, response.LeaseToken)
+
+	return err
+}
+
+// DeleteIdempotencyKey освобождает ключ, если он всё ещё занят запросом с leaseToken.
+func (r *repository) DeleteIdempotencyKey(ctx context.Context, key, leaseToken string) error {
+	ctx, end := startQuery(ctx, "DeleteIdempotencyKey")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start DeleteIdempotencyKey")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `DELETE FROM idempotency_keys WHERE idempotency_key = $1 AND lease_token = $2::uuid AND completed_at IS NULL`
+	_, err := r.client.Exec(ctx, q, key, leaseToken)
+
+	return err
+}
+
+func (r *repository) DeleteExpiredIdempotencyKeys(ctx context.Context, retention time.Duration) (int64, error) {
+	ctx, end := startQuery(ctx, "DeleteExpiredIdempotencyKeys")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start DeleteExpiredIdempotencyKeys")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `DELETE FROM idempotency_keys WHERE created_at < now() - $1::bigint * interval '1 millisecond'`
+	tag, err := r.client.Exec(ctx, q, retention.Milliseconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/jackc/pgx/v5"
+)
+
+var importColumns = []string{"line", "product_code", "product_name", "product_size", "quantity", "storage_id"}
+
+// ImportProducts загружает пачку строк во временную таблицу через COPY и обновляет по ней
+// каталог и остатки. Строки с неизвестным складом или остатком ниже резерва пропускаются
+// и возвращаются как ошибки строк, при повторе кода в пачке применяется последняя строка.
+func (r *repository) ImportProducts(ctx context.Context, rows []models.ImportRow) ([]models.ImportError, error) {
+	ctx, end := startQuery(ctx, "ImportProducts")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start ImportProducts")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	createQ := `CREATE TEMP TABLE import_rows (
+		line INT NOT NULL,
+		product_code VARCHAR (32) NOT NULL,
+		product_name VARCHAR (50) NOT NULL,
+		product_size INT NOT NULL,
+		quantity INT,
+		storage_id INT
+	) ON COMMIT DROP`
+	if _, err := tx.Exec(ctx, createQ); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"import_rows"}, importColumns, pgx.CopyFromSlice(len(rows), func(i int) ([]any, error) {
+		row := rows[i]
+		return []any{row.Line, row.Code, row.Name, row.Size, row.Count, row.Storage}, nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	importErrors := make([]models.ImportError, 0)
+	collect := func(q string, cause error) error {
+		result, err := tx.Query(ctx, q)
+		if err != nil {
+			return err
+		}
+		failed, err := pgx.CollectRows(result, func(row pgx.CollectableRow) (models.ImportError, error) {
+			var line int
+			var code string
+			err := row.Scan(&line, &code)
+			return models.NewImportError(line, code, cause), err
+		})
+		if err != nil {
+			return err
+		}
+		importErrors = append(importErrors, failed...)
+		return nil
+	}
+
+	storageQ := `DELETE FROM import_rows i
+		WHERE i.storage_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM storages st WHERE st.storage_id = i.storage_id)
+		RETURNING i.line, i.product_code`
+	if err := collect(storageQ, models.ErrStorageNotFound); err != nil {
+		return nil, err
+	}
+
+	// блокируем существующие товары, чтобы резервы не изменились до обновления остатков,
+	// в том же режиме, что и lockProducts: резервирование этих товаров получит 409 до конца пачки
+	lockQ := `SELECT p.product_id FROM products p
+		WHERE p.product_code IN (SELECT product_code FROM import_rows)
+		ORDER BY p.product_id FOR NO KEY UPDATE`
+	if _, err := tx.Exec(ctx, lockQ); err != nil {
+		return nil, err
+	}
+
+	belowQ := `DELETE FROM import_rows i
+		USING products p, stocks s
+		WHERE p.product_code = i.product_code
+			AND s.storage_id = i.storage_id AND s.product_id = p.product_id
+			AND i.quantity < ` + reservedSubQ + `
+		RETURNING i.line, i.product_code`
+	if err := collect(belowQ, models.ErrStockBelowReserved); err != nil {
+		return nil, err
+	}
+
+	productQ := `INSERT INTO products (product_code, product_name, product_size)
+		SELECT DISTINCT ON (product_code) product_code, product_name, product_size
+		FROM import_rows ORDER BY product_code, line DESC
+		ON CONFLICT (product_code) DO UPDATE SET
+			product_name = EXCLUDED.product_name,
+			product_size = EXCLUDED.product_size`
+	if _, err := tx.Exec(ctx, productQ); err != nil {
+		return nil, err
+	}
+
+	// последняя строка для каждого остатка, остатки блокируются, чтобы изменение в журнале
+	// совпало с фактическим изменением
+	importedQ := `SELECT DISTINCT ON (i.storage_id, p.product_id) i.storage_id, p.product_id, i.quantity
+		FROM import_rows i JOIN products p ON p.product_code = i.product_code
+		WHERE i.storage_id IS NOT NULL AND i.quantity IS NOT NULL
+		ORDER BY i.storage_id, p.product_id, i.line DESC`
+	lockStocksQ := `SELECT 1 FROM stocks s JOIN (` + importedQ + `) n
+		ON n.storage_id = s.storage_id AND n.product_id = s.product_id
+		ORDER BY s.storage_id, s.product_id FOR UPDATE OF s`
+	if _, err := tx.Exec(ctx, lockStocksQ); err != nil {
+		return nil, err
+	}
+
+	movementQ := `INSERT INTO stock_movements (movement_type, storage_id, product_id, on_hand_change)
+		SELECT 'adjustment', n.storage_id, n.product_id, n.quantity - COALESCE(s.quantity, 0)
+		FROM (` + importedQ + `) n
+		LEFT JOIN stocks s ON s.storage_id = n.storage_id AND s.product_id = n.product_id
+		WHERE n.quantity <> COALESCE(s.quantity, 0)`
+	if _, err := tx.Exec(ctx, movementQ); err != nil {
+		return nil, err
+	}
+
+	stockQ := `INSERT INTO stocks (storage_id, product_id, quantity) ` + importedQ + `
+		ON CONFLICT (storage_id, product_id) DO UPDATE SET quantity = EXCLUDED.quantity`
+	if _, err := tx.Exec(ctx, stockQ); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return importErrors, nil
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateInbound регистрирует поставку, строки с одинаковым товаром суммируются.
+func (r *repository) CreateInbound(ctx context.Context, inbound models.Inbound) (*models.Inbound, error) {
+	ctx, end := startQuery(ctx, "CreateInbound")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CreateInbound")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := storageExists(ctx, tx, inbound.StorageID); err != nil {
+		return nil, err
+	}
+
+	var inboundID uint64
+	headerQ := `INSERT INTO inbound_shipments (storage_id, inbound_reference) VALUES ($1, $2) RETURNING inbound_id`
+	if err := tx.QueryRow(ctx, headerQ, inbound.StorageID, inbound.Reference).Scan(&inboundID); err != nil {
+		return nil, err
+	}
+
+	q := `INSERT INTO inbound_lines (inbound_id, product_id, expected_quantity) VALUES ($1, $2, $3)
+		ON CONFLICT (inbound_id, product_id) DO UPDATE
+		SET expected_quantity = inbound_lines.expected_quantity + EXCLUDED.expected_quantity`
+	batch := &pgx.Batch{}
+	for _, line := range inbound.Lines {
+		batch.Queue(q, inboundID, line.ProductID, line.Expected)
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return r.FindInboundViaID(ctx, inboundID)
+}
+
+func (r *repository) FindInboundViaID(ctx context.Context, inboundID uint64) (*models.Inbound, error) {
+	ctx, end := startQuery(ctx, "FindInboundViaID")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindInboundViaID")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	inbound := &models.Inbound{}
+	q := `SELECT inbound_id, storage_id, inbound_reference, inbound_status, created_at, received_at
+		FROM inbound_shipments WHERE inbound_id = $1`
+	if err := r.client.QueryRow(ctx, q, inboundID).Scan(
+		&inbound.ID, &inbound.StorageID, &inbound.Reference, &inbound.Status, &inbound.CreatedAt, &inbound.ReceivedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrInboundNotFound
+		}
+		return nil, err
+	}
+
+	linesQ := `SELECT l.product_id, p.product_code, COALESCE(p.product_name, ''), l.expected_quantity, l.received_quantity
+		FROM inbound_lines l JOIN products p ON p.product_id = l.product_id
+		WHERE l.inbound_id = $1 ORDER BY p.product_code`
+	rows, err := r.client.Query(ctx, linesQ, inboundID)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.InboundLine, error) {
+		var line models.InboundLine
+		err := row.Scan(&line.ProductID, &line.Code, &line.Name, &line.Expected, &line.Received)
+		return line, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	inbound.Lines = lines
+	inbound.Reconcile()
+
+	return inbound, nil
+}
+
+// ReceiveInbound принимает поставку: фактическое количество добавляется к остаткам склада поставки
+// и записывается в журнал движения. Ожидаемые строки, отсутствующие в приёмке, считаются непринятыми,
+// неожиданные товары добавляются в поставку с нулевым ожидаемым количеством.
+func (r *repository) ReceiveInbound(ctx context.Context, inboundID uint64, lines []models.InboundLine) (*models.Inbound, error) {
+	ctx, end := startQuery(ctx, "ReceiveInbound")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start ReceiveInbound")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// блокировка поставки не даёт принять её дважды
+	var storageID uint
+	var status models.InboundStatus
+	lockQ := `SELECT storage_id, inbound_status FROM inbound_shipments WHERE inbound_id = $1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, lockQ, inboundID).Scan(&storageID, &status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrInboundNotFound
+		}
+		return nil, err
+	}
+	if status != models.InboundExpected {
+		return nil, models.ErrInboundAlreadyReceived
+	}
+
+	// блокировка товаров не даёт приёмке и применению пересчёта перезаписать изменения друг друга
+	products := make([]models.Item, 0, len(lines))
+	for _, line := range lines {
+		if *line.Received > 0 {
+			products = append(products, models.Item{Product: models.Product{ID: line.ProductID, Code: line.Code}})
+		}
+	}
+	if err := lockProducts(ctx, tx, "inbound", products); err != nil {
+		return nil, err
+	}
+
+	lineQ := `INSERT INTO inbound_lines (inbound_id, product_id, expected_quantity, received_quantity)
+		VALUES ($1, $2, 0, $3)
+		ON CONFLICT (inbound_id, product_id) DO UPDATE SET received_quantity = EXCLUDED.received_quantity`
+	stockQ := `INSERT INTO stocks (storage_id, product_id, quantity) VALUES ($1, $2, $3)
+		ON CONFLICT (storage_id, product_id) DO UPDATE SET quantity = stocks.quantity + EXCLUDED.quantity`
+	batch := &pgx.Batch{}
+	movements := make([]models.Movement, 0, len(lines))
+	for _, line := range lines {
+		batch.Queue(lineQ, inboundID, line.ProductID, *line.Received)
+		if *line.Received == 0 {
+			continue
+		}
+		batch.Queue(stockQ, storageID, line.ProductID, *line.Received)
+		movements = append(movements, models.Movement{
+			Type:         models.MovementReceipt,
+			StorageID:    storageID,
+			ProductID:    line.ProductID,
+			OnHandChange: int(*line.Received),
+			InboundID:    &inboundID,
+		})
+	}
+	batch.Queue(`UPDATE inbound_lines SET received_quantity = 0 WHERE inbound_id = $1 AND received_quantity IS NULL`, inboundID)
+	batch.Queue(`UPDATE inbound_shipments SET inbound_status = 'received', received_at = now() WHERE inbound_id = $1`, inboundID)
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return nil, err
+	}
+	if err := recordMovements(ctx, tx, movements); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return r.FindInboundViaID(ctx, inboundID)
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"slices"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/metrics"
+	"github.com/jackc/pgx/v5"
+)
+
+// lockProducts блокирует строки товаров до конца транзакции. Блокировка не ожидает освобождения
+// строк (SKIP LOCKED): если товар уже обрабатывается другой транзакцией, в том числе
+// в другом инстансе сервиса, возвращается ProductsInUseError со списком таких товаров.
+// NO KEY UPDATE не конфликтует с FOR KEY SHARE, которую берут проверки внешних ключей,
+// поэтому вставка строк остатков, резервов и журнала по товару не мешает его блокировке.
+// operation - метка операции в метрике конфликтов блокировок.
+func lockProducts(ctx context.Context, tx pgx.Tx, operation string, products []models.Item) error {
+	ids := make([]uint, 0, len(products))
+	for _, product := range products {
+		if product.ID != 0 {
+			ids = append(ids, product.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	q := `SELECT product_id FROM products WHERE product_id = ANY($1) FOR NO KEY UPDATE SKIP LOCKED`
+	rows, err := tx.Query(ctx, q, ids)
+	if err != nil {
+		return err
+	}
+	locked, err := pgx.CollectRows(rows, pgx.RowTo[uint])
+	if err != nil {
+		return err
+	}
+
+	inUse := make([]string, 0)
+	for _, product := range products {
+		if product.ID != 0 && !slices.Contains(locked, product.ID) && !slices.Contains(inUse, product.Code) {
+			inUse = append(inUse, product.Code)
+		}
+	}
+	if len(inUse) > 0 {
+		metrics.LockConflict(operation)
+		return &models.ProductsInUseError{Codes: inUse}
+	}
+
+	return nil
+}
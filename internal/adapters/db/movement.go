@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/jackc/pgx/v5"
+)
+
+var movementColumns = []string{"movement_type", "storage_id", "product_id", "on_hand_change", "reserved_change", "reservation_id", "inbound_id", "transfer_id", "count_id"}
+
+// reservationMovementsQ записывает в журнал все позиции резерва $1 с типом $2,
+// изменения остатка и резерва равны количеству позиции, умноженному на $3 и $4.
+const reservationMovementsQ = `INSERT INTO stock_movements
+		(movement_type, storage_id, product_id, on_hand_change, reserved_change, reservation_id)
+	SELECT $2, storage_id, product_id, $3::int * quantity, $4::int * quantity, reservation_id
+	FROM reservation_items WHERE reservation_id = $1`
+
+// recordMovements добавляет записи в журнал движения остатков в транзакции изменения остатков.
+func recordMovements(ctx context.Context, tx pgx.Tx, movements []models.Movement) error {
+	if len(movements) == 0 {
+		return nil
+	}
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"stock_movements"}, movementColumns, pgx.CopyFromSlice(len(movements), func(i int) ([]any, error) {
+		m := movements[i]
+		return []any{string(m.Type), m.StorageID, m.ProductID, m.OnHandChange, m.ReservedChange, m.ReservationID, m.InboundID, m.TransferID, m.CountID}, nil
+	}))
+	return err
+}
+
+// FindMovements возвращает журнал движения товара в порядке записи.
+func (r *repository) FindMovements(ctx context.Context, code string, filter models.MovementFilter) ([]models.Movement, error) {
+	ctx, end := startQuery(ctx, "FindMovements")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindMovements")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+
+	var productID uint
+	if err := r.client.QueryRow(ctx, `SELECT product_id FROM products WHERE product_code = $1`, code).Scan(&productID); err != nil {
+		return nil, productError(err)
+	}
+
+	q := `SELECT movement_id, movement_type, storage_id, product_id, $1::text, on_hand_change, reserved_change,
+			reservation_id, inbound_id, transfer_id, count_id, created_at
+		FROM stock_movements
+		WHERE product_id = $2
+			AND ($3::timestamptz IS NULL OR created_at >= $3)
+			AND ($4::timestamptz IS NULL OR created_at < $4)
+		ORDER BY movement_id`
+	rows, err := r.client.Query(ctx, q, code, productID, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Movement, error) {
+		var m models.Movement
+		err := row.Scan(&m.ID, &m.Type, &m.StorageID, &m.ProductID, &m.Code, &m.OnHandChange, &m.ReservedChange,
+			&m.ReservationID, &m.InboundID, &m.TransferID, &m.CountID, &m.CreatedAt)
+		return m, err
+	})
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/jackc/pgx/v5"
+)
+
+// outboxLockKey ключ advisory блокировки, которая оставляет публикацию одному экземпляру сервиса.
+const outboxLockKey = 7_405_126
+
+// RelayOutbox передаёт в publish до limit неотправленных событий в порядке записи и отмечает их
+// отправленными, если publish завершился без ошибки. События транзакций, которые ещё могут
+// выполняться параллельно с более ранними, откладываются до следующего вызова. Пока другой экземпляр публикует события,
+// возвращает 0 без вызова publish.
+func (r *repository) RelayOutbox(ctx context.Context, limit int, publish func([]models.OutboxEvent) error) (int, error) {
+	ctx, end := startQuery(ctx, "RelayOutbox")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start RelayOutbox")
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// публикация одним экземпляром сохраняет порядок событий, параллельные выборки
+	// с SKIP LOCKED могли бы опубликовать события одного товара не по порядку
+	var locked bool
+	if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1)`, outboxLockKey).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	q := `SELECT event_id, event_type, event_key, payload, created_at FROM outbox_events
+		WHERE sent_at IS NULL AND xact_id < pg_snapshot_xmin(pg_current_snapshot())
+		ORDER BY event_id LIMIT $1`
+	rows, err := tx.Query(ctx, q, limit)
+	if err != nil {
+		return 0, err
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.OutboxEvent, error) {
+		var e models.OutboxEvent
+		err := row.Scan(&e.ID, &e.Type, &e.Key, &e.Payload, &e.CreatedAt)
+		return e, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	if err := publish(events); err != nil {
+		return 0, err
+	}
+
+	ids := make([]uint64, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE outbox_events SET sent_at = now() WHERE event_id = ANY ($1)`, ids); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return len(events), nil
+}
+
+// recordReservationEvents записывает в outbox события резервов в транзакции их изменения. Данные события -
+// резерв в том виде, в каком он будет после фиксации транзакции, ключ события - идентификатор резерва.
+func recordReservationEvents(ctx context.Context, tx pgx.Tx, eventType models.EventType, reservationIDs []uint64) error {
+	if len(reservationIDs) == 0 {
+		return nil
+	}
+	q := `INSERT INTO outbox_events (event_type, event_key, payload) VALUES ($1, $2, $3)`
+	batch := &pgx.Batch{}
+	for _, reservationID := range reservationIDs {
+		reservation, err := findReservation(ctx, tx, reservationID)
+		if err != nil {
+			return err
+		}
+		payload, err := json.Marshal(reservation)
+		if err != nil {
+			return err
+		}
+		batch.Queue(q, string(eventType), strconv.FormatUint(reservationID, 10), payload)
+	}
+	return tx.SendBatch(ctx, batch).Close()
+}
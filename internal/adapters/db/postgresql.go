@@ -3,14 +3,16 @@ package db
 import (
 	"context"
 	"errors"
+	"slices"
 	"time"
 
 	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
 	"github.com/Shurubtsov/lamoda-test-task/pkg/client/postgresql"
 	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
-	"github.com/jackc/pgconn"
-	"github.com/jackc/pgerrcode"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/metrics"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/tracing"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type repository struct {
@@ -21,34 +23,57 @@ func New(cl postgresql.Client) *repository {
 	return &repository{client: cl}
 }
 
-func (r *repository) FindAviableStorage(ctx context.Context) (*models.Storage, error) {
-	logger := logging.GetLogger()
-	logger.Trace().Msg("start FindAviableStorage")
+// startQuery начинает спан метода репозитория, возвращаемая функция завершает его
+// и учитывает время выполнения метода в метриках.
+func startQuery(ctx context.Context, name string) (context.Context, func()) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "repository."+name)
+	return ctx, func() {
+		span.End()
+		metrics.ObserveQuery(name, start)
+	}
+}
+
+func (r *repository) FindAviableStorages(ctx context.Context) ([]models.Storage, error) {
+	ctx, end := startQuery(ctx, "FindAviableStorages")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindAviableStorages")
 	ctx, cancel := context.WithTimeout(ctx, time.Second*2)
 	defer cancel()
-	storage := &models.Storage{}
-	q := `SELECT storage_id, storage_aviable, storage_name FROM storages WHERE storage_aviable = true LIMIT 1;`
-	if err := r.client.QueryRow(ctx, q).Scan(&storage.ID, &storage.Aviable, &storage.Name); err != nil {
+	q := `SELECT storage_id, storage_aviable, storage_name, storage_priority FROM storages
+		WHERE storage_aviable = true ORDER BY storage_priority, storage_id;`
+	rows, err := r.client.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	storages, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Storage, error) {
+		storage := models.Storage{ID: new(uint)}
+		err := row.Scan(storage.ID, &storage.Aviable, &storage.Name, &storage.Priority)
+		return storage, err
+	})
+	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			logger.Error().Msgf("SQL Error message (%s), Details: %s where -> %s", pgErr.Message, pgErr.Detail, pgErr.Where)
-			return nil, nil
 		}
-
 		return nil, err
 	}
 
-	logger.Debug().Any("storage", *storage).Msg("storage info")
+	logger.Debug().Any("storages", storages).Msg("aviable storages")
 
-	return storage, nil
+	return storages, nil
 }
 
-func (r *repository) FindProductsViaCode(ctx context.Context, products []models.Product) ([]models.Product, error) {
-	logger := logging.GetLogger()
+func (r *repository) FindProductsViaCode(ctx context.Context, products []models.Item) ([]models.Item, error) {
+	ctx, end := startQuery(ctx, "FindProductsViaCode")
+	defer end()
+	logger := logging.FromContext(ctx)
 	logger.Trace().Msg("start FindProductsViaCode")
 	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
 	defer cancel()
-	q := `SELECT product_id, product_name, product_size, product_count FROM products WHERE product_code = @product_code;`
+	q := `SELECT p.product_id, p.product_name, p.product_size, COALESCE((SELECT SUM(s.quantity) FROM stocks s WHERE s.product_id = p.product_id), 0)
+		FROM products p WHERE p.product_code = @product_code;`
 	batch := &pgx.Batch{}
 	for _, product := range products {
 		args := pgx.NamedArgs{
@@ -68,8 +93,9 @@ func (r *repository) FindProductsViaCode(ctx context.Context, products []models.
 			if errors.As(err, &pgErr) {
 				logger.Error().Msgf("SQL Error message (%s), Details: %s where -> %s", pgErr.Message, pgErr.Detail, pgErr.Where)
 			} else if errors.Is(err, pgx.ErrNoRows) {
-				// было бы хорошо отсюда доставать те товары, которые не заведены в таблице
 				logger.Warn().Err(err).Msgf("product with code: %s not exists", product.Code)
+				product.ID = 0
+				product.SetOutcome(models.ItemNotFound, models.ReasonProductNotInCatalog)
 				continue
 			}
 
@@ -80,66 +106,182 @@ func (r *repository) FindProductsViaCode(ctx context.Context, products []models.
 	return products, nil
 }
 
-func (r *repository) ReserveProducts(ctx context.Context, storage models.Storage, products []models.Product) error {
-	logger := logging.GetLogger()
+func (r *repository) ReserveProducts(ctx context.Context, opts models.ReservationOptions, products []models.Item) (*models.Reservation, []models.Item, error) {
+	ctx, end := startQuery(ctx, "ReserveProducts")
+	defer end()
+	logger := logging.FromContext(ctx)
 	logger.Trace().Msg("start ReserveProducts")
 	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
 	defer cancel()
-	q := `INSERT INTO reservation (storage_id, product_id) VALUES (@storageID, @productID)`
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockProducts(ctx, tx, "reservation", products); err != nil {
+		return nil, nil, err
+	}
+
+	reservation := &models.Reservation{
+		Owner: opts.Owner,
+		Items: make([]models.ReservationItem, 0, len(products)),
+	}
+	headerQ := `INSERT INTO reservations (reservation_owner, expires_at)
+		VALUES ($1, now() + NULLIF($2::bigint, 0) * interval '1 millisecond')
+		RETURNING reservation_id, reservation_status, created_at, updated_at, expires_at`
+	if err := tx.QueryRow(ctx, headerQ, opts.Owner, opts.TTL.Milliseconds()).Scan(
+		&reservation.ID, &reservation.Status, &reservation.CreatedAt, &reservation.UpdatedAt, &reservation.ExpiresAt,
+	); err != nil {
+		return nil, nil, err
+	}
+
+	// позиция резервируется только если свободного остатка на складе (за вычетом активных резервов) достаточно,
+	// остатки распределены заранее, но проверяются повторно под блокировкой товаров
+	q := `WITH stock AS (
+		SELECT s.product_id, s.quantity - ` + reservedSubQ + ` AS available
+		FROM stocks s JOIN storages st ON st.storage_id = s.storage_id
+		WHERE s.storage_id = @storageID AND s.product_id = @productID AND st.storage_aviable = true
+	), inserted AS (
+		INSERT INTO reservation_items (reservation_id, storage_id, product_id, quantity)
+		SELECT @reservationID, @storageID, product_id, @quantity::int FROM stock WHERE available >= @quantity::int
+		ON CONFLICT (reservation_id, storage_id, product_id) DO UPDATE SET quantity = reservation_items.quantity + EXCLUDED.quantity
+		RETURNING product_id
+	)
+	SELECT EXISTS (SELECT 1 FROM inserted);`
 	batch := &pgx.Batch{}
 	for _, product := range products {
-		args := pgx.NamedArgs{
-			"productID": product.ID,
-			"storageID": storage.ID,
+		if product.ID == 0 {
+			continue
+		}
+		for _, allocation := range product.Allocations {
+			args := pgx.NamedArgs{
+				"reservationID": reservation.ID,
+				"productID":     product.ID,
+				"storageID":     allocation.StorageID,
+				"quantity":      allocation.Quantity,
+			}
+			batch.Queue(q, args)
 		}
-		batch.Queue(q, args)
 	}
-	results := r.client.SendBatch(ctx, batch)
-	defer results.Close()
-	for _, product := range products {
-		_, err := results.Exec()
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
-			logger.Error().Msgf("SQL Error message (%s), Details: %s where -> %s", pgErr.Message, pgErr.Detail, pgErr.Where)
-			logger.Warn().Msgf("product %s already exists", product.Name)
+	results := tx.SendBatch(ctx, batch)
+	failed := make([]bool, len(products))
+	allocated := make([][]bool, len(products))
+	for i := 0; i < len(products); i++ {
+		product := &products[i]
+		if product.ID == 0 {
 			continue
 		}
+		allocated[i] = make([]bool, len(product.Allocations))
+		for j := range product.Allocations {
+			var reserved bool
+			if err := results.QueryRow().Scan(&reserved); err != nil {
+				var pgErr *pgconn.PgError
+				if errors.As(err, &pgErr) {
+					logger.Error().Msgf("SQL Error message (%s), Details: %s where -> %s", pgErr.Message, pgErr.Detail, pgErr.Where)
+				}
+				results.Close()
+				return nil, nil, err
+			}
+			allocated[i][j] = reserved
+			if !reserved {
+				failed[i] = true
+			}
+		}
+	}
+	if err := results.Close(); err != nil {
+		return nil, nil, err
 	}
 
-	return nil
-}
+	// товар резервируется целиком: если часть распределения не удалась, из резерва вычитаются
+	// только удавшиеся распределения этого товара, позиции того же товара из других строк запроса остаются
+	deleteQ := `DELETE FROM reservation_items
+		WHERE reservation_id = $1 AND storage_id = $2 AND product_id = $3 AND quantity = $4`
+	decreaseQ := `UPDATE reservation_items SET quantity = quantity - $4
+		WHERE reservation_id = $1 AND storage_id = $2 AND product_id = $3 AND quantity > $4`
+	for i := range products {
+		product := &products[i]
+		if product.ID == 0 || len(product.Allocations) == 0 {
+			continue
+		}
+		if failed[i] {
+			logger.Warn().Msgf("not enough units of product %s", product.Code)
+			rollback := &pgx.Batch{}
+			for j, allocation := range product.Allocations {
+				if !allocated[i][j] {
+					continue
+				}
+				rollback.Queue(deleteQ, reservation.ID, allocation.StorageID, product.ID, allocation.Quantity)
+				rollback.Queue(decreaseQ, reservation.ID, allocation.StorageID, product.ID, allocation.Quantity)
+			}
+			if rollback.Len() > 0 {
+				if err := tx.SendBatch(ctx, rollback).Close(); err != nil {
+					return nil, nil, err
+				}
+			}
+			product.Allocations = nil
+			product.SetOutcome(models.ItemInsufficientStock, models.ReasonAvailableLessRequested)
+			continue
+		}
+		product.Reserved = product.RequestedQuantity()
+		product.Available -= min(product.Reserved, product.Available)
+		product.SetOutcome(models.ItemReserved, "")
+		for _, allocation := range product.Allocations {
+			reservation.Items = append(reservation.Items, models.ReservationItem{
+				StorageID: allocation.StorageID,
+				ProductID: product.ID,
+				Code:      product.Code,
+				Name:      product.Name,
+				Quantity:  allocation.Quantity,
+			})
+		}
+	}
 
-func (r *repository) ExemptProducts(ctx context.Context, products []models.Product) error {
-	logger := logging.GetLogger()
-	logger.Trace().Msg("start ExemptProducts")
-	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
-	defer cancel()
-	q := `DELETE FROM reservation USING products WHERE reservation.product_id = products.product_id AND products.product_id = @productID`
-	batch := &pgx.Batch{}
-	for _, product := range products {
-		args := pgx.NamedArgs{
-			"productID": product.ID,
+	// в атомарном режиме резерв откатывается целиком, если хотя бы один товар не зарезервирован
+	if opts.Mode == models.ModeAtomic && !allReserved(products) {
+		for i := range products {
+			if products[i].Status == models.ItemReserved {
+				products[i].Available += products[i].Reserved
+				products[i].Reserved = 0
+				products[i].SetOutcome(models.ItemRolledBack, models.ReasonAtomicOperationFailed)
+			}
 		}
-		batch.Queue(q, args)
+		return nil, products, models.ErrOperationRolledBack
 	}
-	results := r.client.SendBatch(ctx, batch)
-	defer results.Close()
+
+	// пустой резерв не сохраняем
+	if len(reservation.Items) == 0 {
+		return nil, products, nil
+	}
+
+	if _, err := tx.Exec(ctx, reservationMovementsQ, reservation.ID, models.MovementReservation, 0, 1); err != nil {
+		return nil, nil, err
+	}
+	if err := recordReservationEvents(ctx, tx, models.EventReservationCreated, []uint64{reservation.ID}); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	return reservation, products, nil
+}
+
+func allReserved(products []models.Item) bool {
 	for _, product := range products {
-		_, err := results.Exec()
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			logger.Error().Msgf("SQL Error message (%s), Details: %s where -> %s", pgErr.Message, pgErr.Detail, pgErr.Where)
-			logger.Warn().Msgf("product: %s", product.Name)
-			continue
+		if product.Status != models.ItemReserved {
+			return false
 		}
 	}
-
-	return nil
+	return true
 }
 
-func (r *repository) FindProductsViaStorageID(ctx context.Context, storageID uint) ([]models.Product, error) {
-	logger := logging.GetLogger()
-	logger.Trace().Msg("start FindProductsViaStorageID")
+func (r *repository) ExemptProducts(ctx context.Context, opts models.ExemptionOptions, products []models.Item) ([]models.Item, error) {
+	ctx, end := startQuery(ctx, "ExemptProducts")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start ExemptProducts")
 	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
 	defer cancel()
 	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
@@ -148,62 +290,118 @@ func (r *repository) FindProductsViaStorageID(ctx context.Context, storageID uin
 	}
 	defer tx.Rollback(ctx)
 
-	firstQ := `SELECT product_id FROM reservation WHERE storage_id = $1`
-	rows, err := tx.Query(ctx, firstQ, storageID)
-	if err != nil {
-		logger.Debug().Msg("error after tx.Query()")
+	if err := lockProducts(ctx, tx, "exemption", products); err != nil {
 		return nil, err
 	}
-	products := make([]models.Product, 0)
-	for rows.Next() {
-		var product models.Product
-		err = rows.Scan(&product.ID)
-		if err != nil {
-			logger.Debug().Msg("error after rows.Scan()")
-			return nil, err
-		}
-
-		products = append(products, product)
+	// позиции, перенесённые перемещением в пути, освобождаются только после его завершения или отмены
+	productIDs := make([]uint, 0, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, product.ID)
 	}
-	if err = rows.Err(); err != nil {
-		logger.Debug().Msg("error after rows.Err()")
+	inTransitQ := `SELECT EXISTS (
+		SELECT 1 FROM transfer_reservations t
+		JOIN transfers tr ON tr.transfer_id = t.transfer_id AND tr.transfer_status = 'in_transit'
+		JOIN reservations r ON r.reservation_id = t.reservation_id AND r.reservation_status = 'active'
+		WHERE r.reservation_owner = $1 AND t.product_id = ANY($2)
+	)`
+	var inTransit bool
+	if err := tx.QueryRow(ctx, inTransitQ, opts.Owner, productIDs).Scan(&inTransit); err != nil {
 		return nil, err
 	}
-	rows.Close()
+	if inTransit {
+		return nil, models.ErrReservationInTransit
+	}
 
-	secondQ := `SELECT product_code, product_name, product_size, product_count FROM products WHERE product_id = @productID`
+	// освобождаются только активные резервы владельца, пустые резервы после этого помечаются как released
+	q := `WITH released AS (
+		DELETE FROM reservation_items i USING reservations r
+		WHERE i.reservation_id = r.reservation_id AND r.reservation_status = 'active'
+		AND r.reservation_owner = @owner AND i.product_id = @productID
+		RETURNING i.reservation_id, i.storage_id, i.product_id, i.quantity
+	), logged AS (
+		INSERT INTO stock_movements (movement_type, storage_id, product_id, reserved_change, reservation_id)
+		SELECT 'release', storage_id, product_id, -quantity, reservation_id FROM released
+	)
+	SELECT COALESCE(SUM(quantity), 0), COALESCE(array_agg(DISTINCT reservation_id), '{}') FROM released`
+	releaseQ := `UPDATE reservations r SET reservation_status = 'released', updated_at = now()
+		WHERE r.reservation_status = 'active' AND r.reservation_owner = @owner
+		AND NOT EXISTS (SELECT 1 FROM reservation_items i WHERE i.reservation_id = r.reservation_id)
+		RETURNING r.reservation_id`
 	batch := &pgx.Batch{}
 	for _, product := range products {
 		args := pgx.NamedArgs{
+			"owner":     opts.Owner,
 			"productID": product.ID,
 		}
-		batch.Queue(secondQ, args)
+		batch.Queue(q, args)
 	}
+	batch.Queue(releaseQ, pgx.NamedArgs{"owner": opts.Owner})
 	results := tx.SendBatch(ctx, batch)
+	released := 0
+	// резервы, из которых освобождены позиции, и резервы, освобождённые полностью
+	touched := make([]uint64, 0)
 	for i := 0; i < len(products); i++ {
 		product := &products[i]
-		if err := results.QueryRow().Scan(&product.Code, &product.Name, &product.Size, &product.Count); err != nil {
-			logger.Debug().Msg("error after results.QueryRow()")
-			var (
-				pgErr *pgconn.PgError
-			)
+		var reservationIDs []uint64
+		if err := results.QueryRow().Scan(&product.Released, &reservationIDs); err != nil {
+			var pgErr *pgconn.PgError
 			if errors.As(err, &pgErr) {
 				logger.Error().Msgf("SQL Error message (%s), Details: %s where -> %s", pgErr.Message, pgErr.Detail, pgErr.Where)
-			} else if errors.Is(err, pgx.ErrNoRows) {
-				// было бы хорошо отсюда доставать те товары, которые не заведены в таблице
-				logger.Warn().Err(err).Msgf("product with code: %s not exists", product.Code)
-				continue
+				logger.Warn().Msgf("product: %s", product.Name)
 			}
-
+			results.Close()
 			return nil, err
 		}
+		switch {
+		case product.ID == 0:
+			product.SetOutcome(models.ItemNotFound, models.ReasonProductNotInCatalog)
+		case product.Released == 0:
+			product.SetOutcome(models.ItemNotReserved, models.ReasonNoActiveReservation)
+		default:
+			product.SetOutcome(models.ItemReleased, "")
+			released++
+		}
+		for _, reservationID := range reservationIDs {
+			if !slices.Contains(touched, reservationID) {
+				touched = append(touched, reservationID)
+			}
+		}
+	}
+	rows, err := results.Query()
+	if err != nil {
+		results.Close()
+		return nil, err
+	}
+	fullyReleased, err := pgx.CollectRows(rows, pgx.RowTo[uint64])
+	if err != nil {
+		results.Close()
+		return nil, err
 	}
 	if err := results.Close(); err != nil {
 		return nil, err
 	}
 
+	if opts.Mode == models.ModeAtomic && released != len(products) {
+		for i := range products {
+			if products[i].Status == models.ItemReleased {
+				products[i].Released = 0
+				products[i].SetOutcome(models.ItemRolledBack, models.ReasonAtomicOperationFailed)
+			}
+		}
+		return products, models.ErrOperationRolledBack
+	}
+
+	partiallyReleased := slices.DeleteFunc(touched, func(reservationID uint64) bool {
+		return slices.Contains(fullyReleased, reservationID)
+	})
+	if err := recordReservationEvents(ctx, tx, models.EventReservationReleased, fullyReleased); err != nil {
+		return nil, err
+	}
+	if err := recordReservationEvents(ctx, tx, models.EventReservationPartiallyReleased, partiallyReleased); err != nil {
+		return nil, err
+	}
+
 	if err := tx.Commit(ctx); err != nil {
-		logger.Debug().Msg("error after tx.Commit()")
 		return nil, err
 	}
 
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const productColumns = `product_id, product_code, COALESCE(product_name, ''), COALESCE(product_size, 0)`
+
+func scanProduct(row pgx.CollectableRow) (models.Product, error) {
+	var product models.Product
+	err := row.Scan(&product.ID, &product.Code, &product.Name, &product.Size)
+	return product, err
+}
+
+func (r *repository) CreateProduct(ctx context.Context, product models.Product) (*models.Product, error) {
+	ctx, end := startQuery(ctx, "CreateProduct")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CreateProduct")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `INSERT INTO products (product_code, product_name, product_size) VALUES ($1, $2, $3)
+		RETURNING ` + productColumns
+	rows, err := r.client.Query(ctx, q, product.Code, product.Name, product.Size)
+	if err != nil {
+		return nil, err
+	}
+	created, err := pgx.CollectExactlyOneRow(rows, scanProduct)
+	if err != nil {
+		return nil, productError(err)
+	}
+
+	return &created, nil
+}
+
+func (r *repository) FindProducts(ctx context.Context, limit, offset int) ([]models.Product, error) {
+	ctx, end := startQuery(ctx, "FindProducts")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindProducts")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `SELECT ` + productColumns + ` FROM products ORDER BY product_code LIMIT $1 OFFSET $2`
+	rows, err := r.client.Query(ctx, q, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, scanProduct)
+}
+
+func (r *repository) FindProductViaCode(ctx context.Context, code string) (*models.Product, error) {
+	ctx, end := startQuery(ctx, "FindProductViaCode")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindProductViaCode")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	rows, err := r.client.Query(ctx, `SELECT `+productColumns+` FROM products WHERE product_code = $1`, code)
+	if err != nil {
+		return nil, err
+	}
+	product, err := pgx.CollectExactlyOneRow(rows, scanProduct)
+	if err != nil {
+		return nil, productError(err)
+	}
+
+	return &product, nil
+}
+
+// UpdateProduct изменяет только заполненные поля товара.
+func (r *repository) UpdateProduct(ctx context.Context, code string, patch models.ProductPatch) (*models.Product, error) {
+	ctx, end := startQuery(ctx, "UpdateProduct")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start UpdateProduct")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `UPDATE products SET
+			product_code = COALESCE($2, product_code),
+			product_name = COALESCE($3, product_name),
+			product_size = COALESCE($4::int, product_size)
+		WHERE product_code = $1
+		RETURNING ` + productColumns
+	rows, err := r.client.Query(ctx, q, code, patch.Code, patch.Name, patch.Size)
+	if err != nil {
+		return nil, err
+	}
+	product, err := pgx.CollectExactlyOneRow(rows, scanProduct)
+	if err != nil {
+		return nil, productError(err)
+	}
+
+	return &product, nil
+}
+
+// DeleteProduct удаляет товар без активных резервов и остатков вместе с его нулевыми остатками.
+func (r *repository) DeleteProduct(ctx context.Context, code string) error {
+	ctx, end := startQuery(ctx, "DeleteProduct")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start DeleteProduct")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// блокировка товара не даёт параллельно зарезервировать его или изменить остаток
+	var productID uint
+	lockQ := `SELECT product_id FROM products WHERE product_code = $1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, lockQ, code).Scan(&productID); err != nil {
+		return productError(err)
+	}
+
+	var hasReservations, hasStock bool
+	checkQ := `SELECT
+		EXISTS (
+			SELECT 1 FROM reservation_items i JOIN reservations r ON r.reservation_id = i.reservation_id
+			WHERE i.product_id = $1 AND r.reservation_status = 'active'
+		),
+		EXISTS (SELECT 1 FROM stocks WHERE product_id = $1 AND quantity > 0)`
+	if err := tx.QueryRow(ctx, checkQ, productID).Scan(&hasReservations, &hasStock); err != nil {
+		return err
+	}
+	if hasReservations {
+		return models.ErrProductHasReservations
+	}
+	if hasStock {
+		return models.ErrProductHasStock
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM stocks WHERE product_id = $1`, productID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM products WHERE product_id = $1`, productID); err != nil {
+		return productError(err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// productError сопоставляет ошибки PostgreSQL с ошибками каталога товаров.
+func productError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.ErrProductNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgerrcode.UniqueViolation:
+			return models.ErrProductCodeExists
+		case pgerrcode.ForeignKeyViolation:
+			return models.ErrProductHasHistory
+		}
+	}
+	return err
+}
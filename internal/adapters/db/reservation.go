@@ -0,0 +1,249 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func (r *repository) FindReservationViaID(ctx context.Context, reservationID uint64) (*models.Reservation, error) {
+	ctx, end := startQuery(ctx, "FindReservationViaID")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindReservationViaID")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	return findReservation(ctx, r.client, reservationID)
+}
+
+func findReservation(ctx context.Context, db querier, reservationID uint64) (*models.Reservation, error) {
+	reservation := &models.Reservation{}
+	q := `SELECT reservation_id, reservation_owner, reservation_status, created_at, updated_at, expires_at
+		FROM reservations WHERE reservation_id = $1`
+	if err := db.QueryRow(ctx, q, reservationID).Scan(
+		&reservation.ID, &reservation.Owner, &reservation.Status, &reservation.CreatedAt, &reservation.UpdatedAt, &reservation.ExpiresAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrReservationNotFound
+		}
+		return nil, err
+	}
+
+	itemsQ := `SELECT i.storage_id, i.product_id, p.product_code, COALESCE(p.product_name, ''), i.quantity
+		FROM reservation_items i JOIN products p ON p.product_id = i.product_id
+		WHERE i.reservation_id = $1 ORDER BY i.product_id`
+	rows, err := db.Query(ctx, itemsQ, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.ReservationItem, error) {
+		var item models.ReservationItem
+		err := row.Scan(&item.StorageID, &item.ProductID, &item.Code, &item.Name, &item.Quantity)
+		return item, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	reservation.Items = items
+
+	return reservation, nil
+}
+
+// SetReservationStatus переводит активный резерв в указанный статус, единицы резерва освобождаются.
+func (r *repository) SetReservationStatus(ctx context.Context, reservationID uint64, status models.ReservationStatus) error {
+	ctx, end := startQuery(ctx, "SetReservationStatus")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start SetReservationStatus")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `UPDATE reservations SET reservation_status = $2, updated_at = now()
+		WHERE reservation_id = $1 AND reservation_status = 'active'`
+	tag, err := tx.Exec(ctx, q, reservationID, status)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrReservationNotActive
+	}
+	if err := checkNotInTransit(ctx, tx, reservationID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, reservationMovementsQ, reservationID, models.MovementRelease, 0, -1); err != nil {
+		return err
+	}
+	if status == models.ReservationReleased {
+		if err := recordReservationEvents(ctx, tx, models.EventReservationReleased, []uint64{reservationID}); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// FulfilReservation закрывает резерв и списывает зарезервированные единицы со складов.
+func (r *repository) FulfilReservation(ctx context.Context, reservationID uint64) error {
+	ctx, end := startQuery(ctx, "FulfilReservation")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FulfilReservation")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	statusQ := `UPDATE reservations SET reservation_status = 'fulfilled', updated_at = now()
+		WHERE reservation_id = $1 AND reservation_status = 'active'`
+	tag, err := tx.Exec(ctx, statusQ, reservationID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrReservationNotActive
+	}
+	// перенесённые позиции ещё не поступили на склад назначения, списывать их нечем
+	if err := checkNotInTransit(ctx, tx, reservationID); err != nil {
+		return err
+	}
+
+	// каждая позиция резерва должна списаться со строки остатков, иначе товара на складе нет
+	stockQ := `WITH items AS (
+		SELECT storage_id, product_id, SUM(quantity) AS quantity FROM reservation_items
+		WHERE reservation_id = $1 GROUP BY storage_id, product_id
+	), updated AS (
+		UPDATE stocks s SET quantity = s.quantity - i.quantity
+		FROM items i WHERE s.storage_id = i.storage_id AND s.product_id = i.product_id
+		RETURNING s.storage_id
+	)
+	SELECT (SELECT count(*) FROM items), (SELECT count(*) FROM updated)`
+	var itemsCount, updatedCount int64
+	if err := tx.QueryRow(ctx, stockQ, reservationID).Scan(&itemsCount, &updatedCount); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation {
+			return models.ErrStockNotOnHand
+		}
+		return err
+	}
+	if updatedCount != itemsCount {
+		return models.ErrStockNotOnHand
+	}
+	if _, err := tx.Exec(ctx, reservationMovementsQ, reservationID, models.MovementShipment, -1, -1); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// checkNotInTransit возвращает ErrReservationInTransit, если позиции резерва перенесены
+// перемещением, которое ещё в пути. Вызывается после блокировки строки резерва: перемещение
+// блокирует резерв при переносе, поэтому проверка видит все зафиксированные переносы.
+func checkNotInTransit(ctx context.Context, tx pgx.Tx, reservationID uint64) error {
+	q := `SELECT EXISTS (
+		SELECT 1 FROM transfer_reservations t JOIN transfers tr ON tr.transfer_id = t.transfer_id
+		WHERE t.reservation_id = $1 AND tr.transfer_status = 'in_transit'
+	)`
+	var inTransit bool
+	if err := tx.QueryRow(ctx, q, reservationID).Scan(&inTransit); err != nil {
+		return err
+	}
+	if inTransit {
+		return models.ErrReservationInTransit
+	}
+	return nil
+}
+
+// ExtendReservation продлевает активный и ещё не истёкший резерв на ttl от текущего момента.
+func (r *repository) ExtendReservation(ctx context.Context, reservationID uint64, ttl time.Duration) error {
+	ctx, end := startQuery(ctx, "ExtendReservation")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start ExtendReservation")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `UPDATE reservations SET expires_at = now() + $2::bigint * interval '1 millisecond', updated_at = now()
+		WHERE reservation_id = $1 AND reservation_status = 'active' AND (expires_at IS NULL OR expires_at > now())`
+	tag, err := r.client.Exec(ctx, q, reservationID, ttl.Milliseconds())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrReservationNotActive
+	}
+
+	return nil
+}
+
+// ExpireReservations переводит истёкшие резервы в статус expired и записывает освобождение их позиций в журнал.
+// Резервы, позиции которых перенесены перемещением в пути, истекают после его завершения или отмены.
+// Строки блокируются через SKIP LOCKED, поэтому несколько инстансов сервиса
+// могут выполнять очистку одновременно, не обрабатывая один резерв дважды.
+func (r *repository) ExpireReservations(ctx context.Context, limit int) ([]uint64, error) {
+	ctx, end := startQuery(ctx, "ExpireReservations")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start ExpireReservations")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `WITH expired AS (
+		UPDATE reservations SET reservation_status = 'expired', updated_at = now()
+		WHERE reservation_id IN (
+			SELECT reservation_id FROM reservations
+			WHERE reservation_status = 'active' AND expires_at <= now()
+			AND NOT EXISTS (
+				SELECT 1 FROM transfer_reservations t JOIN transfers tr ON tr.transfer_id = t.transfer_id
+				WHERE t.reservation_id = reservations.reservation_id AND tr.transfer_status = 'in_transit'
+			)
+			ORDER BY expires_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		) AND reservation_status = 'active'
+		RETURNING reservation_id
+	), logged AS (
+		INSERT INTO stock_movements (movement_type, storage_id, product_id, reserved_change, reservation_id)
+		SELECT 'release', i.storage_id, i.product_id, -i.quantity, i.reservation_id
+		FROM reservation_items i JOIN expired e ON e.reservation_id = i.reservation_id
+	)
+	SELECT reservation_id FROM expired`
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, q, limit)
+	if err != nil {
+		return nil, err
+	}
+	expired, err := pgx.CollectRows(rows, pgx.RowTo[uint64])
+	if err != nil {
+		return nil, err
+	}
+	if err := recordReservationEvents(ctx, tx, models.EventReservationExpired, expired); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,260 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/jackc/pgx/v5"
+)
+
+// reservedSubQ количество единиц товара на складе в активных резервах, ожидает алиас s для stocks.
+const reservedSubQ = `COALESCE((
+	SELECT SUM(i.quantity) FROM reservation_items i
+	JOIN reservations r ON r.reservation_id = i.reservation_id
+	WHERE i.storage_id = s.storage_id AND i.product_id = s.product_id AND r.reservation_status = 'active'
+), 0)`
+
+// exportPageSize количество остатков, читаемых одним запросом при выгрузке склада.
+const exportPageSize = 1000
+
+// storageStocksQ остатки всех товаров склада, колонки соответствуют scanStock.
+const storageStocksQ = `SELECT s.storage_id, s.product_id, p.product_code, COALESCE(p.product_name, ''), COALESCE(p.product_size, 0),
+		s.quantity, ` + reservedSubQ + `
+	FROM stocks s JOIN products p ON p.product_id = s.product_id
+	WHERE s.storage_id = $1 ORDER BY p.product_code`
+
+// FindStockLevels возвращает остатки и активные резервы товаров на доступных складах.
+func (r *repository) FindStockLevels(ctx context.Context, productIDs []uint) ([]models.Stock, error) {
+	ctx, end := startQuery(ctx, "FindStockLevels")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindStockLevels")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	q := `SELECT s.storage_id, s.product_id, s.quantity, ` + reservedSubQ + `
+		FROM stocks s JOIN storages st ON st.storage_id = s.storage_id
+		WHERE st.storage_aviable = true AND s.product_id = ANY($1)`
+	rows, err := r.client.Query(ctx, q, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Stock, error) {
+		var stock models.Stock
+		err := row.Scan(&stock.StorageID, &stock.ProductID, &stock.OnHand, &stock.Reserved)
+		stock.Available = stock.OnHand - min(stock.Reserved, stock.OnHand)
+		return stock, err
+	})
+}
+
+// FindStocksViaStorageID возвращает остатки всех товаров склада.
+func (r *repository) FindStocksViaStorageID(ctx context.Context, storageID uint) ([]models.Stock, error) {
+	ctx, end := startQuery(ctx, "FindStocksViaStorageID")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindStocksViaStorageID")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	if err := storageExists(ctx, r.client, storageID); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.client.Query(ctx, storageStocksQ, storageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, scanStock)
+}
+
+// StreamStocksViaStorageID передаёт остатки склада в fn страницами по exportPageSize строк в порядке кода товара.
+// Каждая страница читается отдельным коротким запросом, поэтому медленный получатель не удерживает
+// соединение пула, а выгрузка не является единым снимком остатков.
+func (r *repository) StreamStocksViaStorageID(ctx context.Context, storageID uint, fn func(models.Stock) error) error {
+	ctx, end := startQuery(ctx, "StreamStocksViaStorageID")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start StreamStocksViaStorageID")
+	ctx, cancel := context.WithTimeout(ctx, time.Minute*5)
+	defer cancel()
+
+	if err := storageExists(ctx, r.client, storageID); err != nil {
+		return err
+	}
+
+	after := ""
+	for {
+		page, err := r.stocksPage(ctx, storageID, after)
+		if err != nil {
+			return err
+		}
+		for _, stock := range page {
+			if err := fn(stock); err != nil {
+				return err
+			}
+		}
+		if len(page) < exportPageSize {
+			return nil
+		}
+		after = page[len(page)-1].Code
+	}
+}
+
+// stocksPage возвращает до exportPageSize остатков склада с кодом товара больше after.
+func (r *repository) stocksPage(ctx context.Context, storageID uint, after string) ([]models.Stock, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `SELECT s.storage_id, s.product_id, p.product_code, COALESCE(p.product_name, ''), COALESCE(p.product_size, 0),
+			s.quantity, ` + reservedSubQ + `
+		FROM stocks s JOIN products p ON p.product_id = s.product_id
+		WHERE s.storage_id = $1 AND p.product_code > $2 ORDER BY p.product_code LIMIT $3`
+	rows, err := r.client.Query(ctx, q, storageID, after, exportPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, scanStock)
+}
+
+// SetStocks устанавливает количество товаров на складе. Остаток нельзя опустить ниже
+// количества в активных резервах, в этом случае изменения не применяются.
+func (r *repository) SetStocks(ctx context.Context, storageID uint, stocks []models.Stock) ([]models.Stock, error) {
+	ctx, end := startQuery(ctx, "SetStocks")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start SetStocks")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := storageExists(ctx, tx, storageID); err != nil {
+		return nil, err
+	}
+
+	products := make([]models.Item, 0, len(stocks))
+	for _, stock := range stocks {
+		products = append(products, models.Item{Product: models.Product{ID: stock.ProductID, Code: stock.Code}})
+	}
+	if err := lockProducts(ctx, tx, "stock", products); err != nil {
+		return nil, err
+	}
+
+	productIDs := make([]uint, 0, len(stocks))
+	for _, stock := range stocks {
+		productIDs = append(productIDs, stock.ProductID)
+	}
+	previous, err := lockStocks(ctx, tx, storageID, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `INSERT INTO stocks (storage_id, product_id, quantity) VALUES (@storageID, @productID, @quantity)
+		ON CONFLICT (storage_id, product_id) DO UPDATE SET quantity = EXCLUDED.quantity`
+	checkQ := `SELECT s.storage_id, s.product_id, p.product_code, COALESCE(p.product_name, ''), COALESCE(p.product_size, 0),
+			s.quantity, ` + reservedSubQ + `
+		FROM stocks s JOIN products p ON p.product_id = s.product_id
+		WHERE s.storage_id = $1 AND s.product_id = ANY($2) ORDER BY p.product_code`
+	batch := &pgx.Batch{}
+	movements := make([]models.Movement, 0, len(stocks))
+	for _, stock := range stocks {
+		batch.Queue(q, pgx.NamedArgs{
+			"storageID": storageID,
+			"productID": stock.ProductID,
+			"quantity":  stock.OnHand,
+		})
+		if change := int(stock.OnHand) - int(previous[stock.ProductID]); change != 0 {
+			movements = append(movements, models.Movement{
+				Type:         models.MovementAdjustment,
+				StorageID:    storageID,
+				ProductID:    stock.ProductID,
+				OnHandChange: change,
+			})
+			previous[stock.ProductID] = stock.OnHand
+		}
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return nil, err
+	}
+	if err := recordMovements(ctx, tx, movements); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, checkQ, storageID, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := pgx.CollectRows(rows, scanStock)
+	if err != nil {
+		return nil, err
+	}
+
+	belowReserved := make([]models.Stock, 0)
+	for _, stock := range updated {
+		if stock.OnHand < stock.Reserved {
+			belowReserved = append(belowReserved, stock)
+		}
+	}
+	if len(belowReserved) > 0 {
+		return nil, &models.StockBelowReservedError{Stocks: belowReserved}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// lockStocks блокирует остатки товаров на складе до конца транзакции и возвращает их количество,
+// отсутствующие остатки считаются нулевыми.
+func lockStocks(ctx context.Context, tx pgx.Tx, storageID uint, productIDs []uint) (map[uint]uint, error) {
+	q := `SELECT product_id, quantity FROM stocks WHERE storage_id = $1 AND product_id = ANY($2)
+		ORDER BY product_id FOR UPDATE`
+	rows, err := tx.Query(ctx, q, storageID, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	quantities := make(map[uint]uint, len(productIDs))
+	var productID, quantity uint
+	_, err = pgx.ForEachRow(rows, []any{&productID, &quantity}, func() error {
+		quantities[productID] = quantity
+		return nil
+	})
+	return quantities, err
+}
+
+// querier общая часть пула и транзакции, нужная для чтения внутри транзакции.
+type querier interface {
+	Query(ctx context.Context, sql string, arguments ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, arguments ...any) pgx.Row
+}
+
+func storageExists(ctx context.Context, db querier, storageID uint) error {
+	var exists bool
+	q := `SELECT EXISTS (SELECT 1 FROM storages WHERE storage_id = $1)`
+	if err := db.QueryRow(ctx, q, storageID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return models.ErrStorageNotFound
+	}
+	return nil
+}
+
+func scanStock(row pgx.CollectableRow) (models.Stock, error) {
+	var stock models.Stock
+	err := row.Scan(&stock.StorageID, &stock.ProductID, &stock.Code, &stock.Name, &stock.Size, &stock.OnHand, &stock.Reserved)
+	if err != nil {
+		return stock, err
+	}
+	stock.Available = stock.OnHand - min(stock.Reserved, stock.OnHand)
+	return stock, nil
+}
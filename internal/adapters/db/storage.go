@@ -0,0 +1,174 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const storageColumns = `storage_id, storage_name, storage_aviable, storage_priority`
+
+func scanStorage(row pgx.CollectableRow) (models.Storage, error) {
+	storage := models.Storage{ID: new(uint)}
+	err := row.Scan(storage.ID, &storage.Name, &storage.Aviable, &storage.Priority)
+	return storage, err
+}
+
+func (r *repository) CreateStorage(ctx context.Context, storage models.Storage) (*models.Storage, error) {
+	ctx, end := startQuery(ctx, "CreateStorage")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CreateStorage")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `INSERT INTO storages (storage_name, storage_aviable, storage_priority) VALUES ($1, $2, $3)
+		RETURNING ` + storageColumns
+	rows, err := r.client.Query(ctx, q, storage.Name, storage.Aviable, storage.Priority)
+	if err != nil {
+		return nil, err
+	}
+	created, err := pgx.CollectExactlyOneRow(rows, scanStorage)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return &created, nil
+}
+
+func (r *repository) FindStorages(ctx context.Context) ([]models.Storage, error) {
+	ctx, end := startQuery(ctx, "FindStorages")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindStorages")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	rows, err := r.client.Query(ctx, `SELECT `+storageColumns+` FROM storages ORDER BY storage_priority, storage_id`)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, scanStorage)
+}
+
+func (r *repository) FindStorageViaID(ctx context.Context, storageID uint) (*models.Storage, error) {
+	ctx, end := startQuery(ctx, "FindStorageViaID")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindStorageViaID")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	rows, err := r.client.Query(ctx, `SELECT `+storageColumns+` FROM storages WHERE storage_id = $1`, storageID)
+	if err != nil {
+		return nil, err
+	}
+	storage, err := pgx.CollectExactlyOneRow(rows, scanStorage)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return &storage, nil
+}
+
+// UpdateStorage изменяет только заполненные поля склада.
+func (r *repository) UpdateStorage(ctx context.Context, storageID uint, patch models.StoragePatch) (*models.Storage, error) {
+	ctx, end := startQuery(ctx, "UpdateStorage")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start UpdateStorage")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `UPDATE storages SET
+			storage_name = COALESCE($2, storage_name),
+			storage_aviable = COALESCE($3, storage_aviable),
+			storage_priority = COALESCE($4, storage_priority)
+		WHERE storage_id = $1
+		RETURNING ` + storageColumns
+	rows, err := r.client.Query(ctx, q, storageID, patch.Name, patch.Aviable, patch.Priority)
+	if err != nil {
+		return nil, err
+	}
+	storage, err := pgx.CollectExactlyOneRow(rows, scanStorage)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return &storage, nil
+}
+
+// DeleteStorage удаляет склад без активных резервов и остатков вместе с его нулевыми остатками.
+func (r *repository) DeleteStorage(ctx context.Context, storageID uint) error {
+	ctx, end := startQuery(ctx, "DeleteStorage")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start DeleteStorage")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// блокируем склад, чтобы параллельно не появились новые резервы на нём
+	var exists bool
+	lockQ := `SELECT EXISTS (SELECT 1 FROM storages WHERE storage_id = $1 FOR UPDATE)`
+	if err := tx.QueryRow(ctx, lockQ, storageID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return models.ErrStorageNotFound
+	}
+
+	var hasReservations, hasStock bool
+	checkQ := `SELECT
+		EXISTS (
+			SELECT 1 FROM reservation_items i JOIN reservations r ON r.reservation_id = i.reservation_id
+			WHERE i.storage_id = $1 AND r.reservation_status = 'active'
+		),
+		EXISTS (SELECT 1 FROM stocks WHERE storage_id = $1 AND quantity > 0)`
+	if err := tx.QueryRow(ctx, checkQ, storageID).Scan(&hasReservations, &hasStock); err != nil {
+		return err
+	}
+	if hasReservations {
+		return models.ErrStorageHasReservations
+	}
+	if hasStock {
+		return models.ErrStorageHasStock
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM stocks WHERE storage_id = $1`, storageID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM storages WHERE storage_id = $1`, storageID); err != nil {
+		return storageError(err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// storageError сопоставляет ошибки PostgreSQL с ошибками склада.
+func storageError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.ErrStorageNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgerrcode.UniqueViolation:
+			return models.ErrStorageNameExists
+		case pgerrcode.ForeignKeyViolation:
+			return models.ErrStorageHasHistory
+		}
+	}
+	return err
+}
@@ -0,0 +1,389 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/jackc/pgx/v5"
+)
+
+// moveReservationItemQ переносит $4 единиц позиции резерва $1 товара $5 со склада $2 на склад $3,
+// опустевшая позиция на исходном складе удаляется.
+const moveReservationItemQ = `WITH source AS (
+		UPDATE reservation_items SET quantity = quantity - $4::int
+		WHERE reservation_id = $1 AND storage_id = $2 AND product_id = $5 AND quantity > $4::int
+		RETURNING reservation_id
+	), emptied AS (
+		DELETE FROM reservation_items
+		WHERE reservation_id = $1 AND storage_id = $2 AND product_id = $5 AND quantity = $4::int
+		RETURNING reservation_id
+	)
+	INSERT INTO reservation_items (reservation_id, storage_id, product_id, quantity)
+	SELECT $1, $3, $5, $4::int WHERE EXISTS (SELECT 1 FROM source UNION ALL SELECT 1 FROM emptied)
+	ON CONFLICT (reservation_id, storage_id, product_id) DO UPDATE SET quantity = reservation_items.quantity + EXCLUDED.quantity`
+
+type reservedItem struct {
+	reservationID uint64
+	productID     uint
+	quantity      uint
+}
+
+// CreateTransfer списывает товары со склада-источника и переводит их в пути. При политике block
+// перемещаются только свободные единицы, при migrate недостающее количество берётся из активных резервов,
+// позиции которых переносятся на склад назначения, начиная с самых новых резервов.
+func (r *repository) CreateTransfer(ctx context.Context, transfer models.Transfer) (*models.Transfer, error) {
+	ctx, end := startQuery(ctx, "CreateTransfer")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CreateTransfer")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := storageExists(ctx, tx, transfer.SourceStorageID); err != nil {
+		return nil, err
+	}
+	if err := storageExists(ctx, tx, transfer.DestinationStorageID); err != nil {
+		return nil, err
+	}
+
+	products := make([]models.Item, 0, len(transfer.Lines))
+	productIDs := make([]uint, 0, len(transfer.Lines))
+	for _, line := range transfer.Lines {
+		products = append(products, models.Item{Product: models.Product{ID: line.ProductID, Code: line.Code}})
+		productIDs = append(productIDs, line.ProductID)
+	}
+	if err := lockProducts(ctx, tx, "transfer", products); err != nil {
+		return nil, err
+	}
+
+	// резервы блокируются раньше остатков, в том же порядке, что и при выполнении резерва
+	itemsQ := `SELECT i.reservation_id, i.product_id, i.quantity
+		FROM reservation_items i JOIN reservations r ON r.reservation_id = i.reservation_id
+		WHERE i.storage_id = $1 AND i.product_id = ANY($2) AND r.reservation_status = 'active'
+		ORDER BY i.reservation_id DESC`
+	if transfer.Reservations == models.ReservationsMigrate {
+		itemsQ += ` FOR UPDATE OF i, r`
+	}
+	rows, err := tx.Query(ctx, itemsQ, transfer.SourceStorageID, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	var item reservedItem
+	items := make([]reservedItem, 0)
+	reserved := make(map[uint]uint, len(productIDs))
+	_, err = pgx.ForEachRow(rows, []any{&item.reservationID, &item.productID, &item.quantity}, func() error {
+		items = append(items, item)
+		reserved[item.productID] += item.quantity
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	onHand, err := lockStocks(ctx, tx, transfer.SourceStorageID, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	insufficient := make([]models.TransferLine, 0)
+	for i := range transfer.Lines {
+		line := &transfer.Lines[i]
+		available := onHand[line.ProductID] - min(reserved[line.ProductID], onHand[line.ProductID])
+		movable := available
+		if transfer.Reservations == models.ReservationsMigrate {
+			movable = onHand[line.ProductID]
+		}
+		if line.Quantity > movable {
+			line.Available = movable
+			insufficient = append(insufficient, *line)
+			continue
+		}
+		if line.Quantity > available {
+			line.Migrated = line.Quantity - available
+		}
+	}
+	if len(insufficient) > 0 {
+		return nil, &models.InsufficientStockError{Lines: insufficient}
+	}
+
+	var transferID uint64
+	headerQ := `INSERT INTO transfers (source_storage_id, destination_storage_id, reservation_policy)
+		VALUES ($1, $2, $3) RETURNING transfer_id`
+	if err := tx.QueryRow(ctx, headerQ, transfer.SourceStorageID, transfer.DestinationStorageID, transfer.Reservations).Scan(&transferID); err != nil {
+		return nil, err
+	}
+
+	lineQ := `INSERT INTO transfer_lines (transfer_id, product_id, quantity) VALUES ($1, $2, $3)`
+	stockQ := `UPDATE stocks SET quantity = quantity - $3 WHERE storage_id = $1 AND product_id = $2`
+	migratedQ := `INSERT INTO transfer_reservations (transfer_id, reservation_id, product_id, quantity) VALUES ($1, $2, $3, $4)`
+	batch := &pgx.Batch{}
+	movements := make([]models.Movement, 0, len(transfer.Lines))
+	for _, line := range transfer.Lines {
+		batch.Queue(lineQ, transferID, line.ProductID, line.Quantity)
+		batch.Queue(stockQ, transfer.SourceStorageID, line.ProductID, line.Quantity)
+		movements = append(movements, models.Movement{
+			Type:         models.MovementTransfer,
+			StorageID:    transfer.SourceStorageID,
+			ProductID:    line.ProductID,
+			OnHandChange: -int(line.Quantity),
+			TransferID:   &transferID,
+		})
+
+		need := line.Migrated
+		for _, item := range items {
+			if need == 0 {
+				break
+			}
+			if item.productID != line.ProductID {
+				continue
+			}
+			take := min(need, item.quantity)
+			need -= take
+			batch.Queue(moveReservationItemQ, item.reservationID, transfer.SourceStorageID, transfer.DestinationStorageID, take, line.ProductID)
+			batch.Queue(migratedQ, transferID, item.reservationID, line.ProductID, take)
+			reservationID := item.reservationID
+			movements = append(movements, models.Movement{
+				Type:           models.MovementTransfer,
+				StorageID:      transfer.SourceStorageID,
+				ProductID:      line.ProductID,
+				ReservedChange: -int(take),
+				ReservationID:  &reservationID,
+				TransferID:     &transferID,
+			}, models.Movement{
+				Type:           models.MovementTransfer,
+				StorageID:      transfer.DestinationStorageID,
+				ProductID:      line.ProductID,
+				ReservedChange: int(take),
+				ReservationID:  &reservationID,
+				TransferID:     &transferID,
+			})
+		}
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return nil, err
+	}
+	if err := recordMovements(ctx, tx, movements); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return r.FindTransferViaID(ctx, transferID)
+}
+
+func (r *repository) FindTransferViaID(ctx context.Context, transferID uint64) (*models.Transfer, error) {
+	ctx, end := startQuery(ctx, "FindTransferViaID")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindTransferViaID")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	transfer := &models.Transfer{}
+	q := `SELECT transfer_id, source_storage_id, destination_storage_id, reservation_policy, transfer_status, created_at, updated_at
+		FROM transfers WHERE transfer_id = $1`
+	if err := r.client.QueryRow(ctx, q, transferID).Scan(
+		&transfer.ID, &transfer.SourceStorageID, &transfer.DestinationStorageID, &transfer.Reservations,
+		&transfer.Status, &transfer.CreatedAt, &transfer.UpdatedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrTransferNotFound
+		}
+		return nil, err
+	}
+
+	linesQ := `SELECT l.product_id, p.product_code, COALESCE(p.product_name, ''), l.quantity,
+			COALESCE((SELECT SUM(t.quantity) FROM transfer_reservations t
+				WHERE t.transfer_id = l.transfer_id AND t.product_id = l.product_id), 0)
+		FROM transfer_lines l JOIN products p ON p.product_id = l.product_id
+		WHERE l.transfer_id = $1 ORDER BY p.product_code`
+	rows, err := r.client.Query(ctx, linesQ, transferID)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.TransferLine, error) {
+		var line models.TransferLine
+		err := row.Scan(&line.ProductID, &line.Code, &line.Name, &line.Quantity, &line.Migrated)
+		return line, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	transfer.Lines = lines
+
+	return transfer, nil
+}
+
+// CompleteTransfer зачисляет товары в пути на склад назначения.
+func (r *repository) CompleteTransfer(ctx context.Context, transferID uint64) (*models.Transfer, error) {
+	ctx, end := startQuery(ctx, "CompleteTransfer")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CompleteTransfer")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	transfer, err := lockTransfer(ctx, tx, transferID, models.TransferCompleted)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := arriveTransfer(ctx, tx, transferID, transfer.DestinationStorageID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return r.FindTransferViaID(ctx, transferID)
+}
+
+// CancelTransfer возвращает товары в пути на склад-источник, перенесённые позиции резервов,
+// которые ещё активны, возвращаются на склад-источник в пределах оставшегося количества.
+func (r *repository) CancelTransfer(ctx context.Context, transferID uint64) (*models.Transfer, error) {
+	ctx, end := startQuery(ctx, "CancelTransfer")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CancelTransfer")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	transfer, err := lockTransfer(ctx, tx, transferID, models.TransferCancelled)
+	if err != nil {
+		return nil, err
+	}
+
+	if transfer.Reservations == models.ReservationsMigrate {
+		if err := returnReservations(ctx, tx, transfer); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := arriveTransfer(ctx, tx, transferID, transfer.SourceStorageID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return r.FindTransferViaID(ctx, transferID)
+}
+
+// lockTransfer блокирует перемещение в пути и переводит его в статус status.
+func lockTransfer(ctx context.Context, tx pgx.Tx, transferID uint64, status models.TransferStatus) (*models.Transfer, error) {
+	transfer := &models.Transfer{ID: transferID}
+	lockQ := `SELECT source_storage_id, destination_storage_id, reservation_policy, transfer_status
+		FROM transfers WHERE transfer_id = $1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, lockQ, transferID).Scan(
+		&transfer.SourceStorageID, &transfer.DestinationStorageID, &transfer.Reservations, &transfer.Status,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrTransferNotFound
+		}
+		return nil, err
+	}
+	if transfer.Status != models.TransferInTransit {
+		return nil, models.ErrTransferNotInTransit
+	}
+
+	statusQ := `UPDATE transfers SET transfer_status = $2, updated_at = now() WHERE transfer_id = $1`
+	if _, err := tx.Exec(ctx, statusQ, transferID, status); err != nil {
+		return nil, err
+	}
+	transfer.Status = status
+
+	return transfer, nil
+}
+
+// arriveTransfer зачисляет строки перемещения на склад storageID.
+func arriveTransfer(ctx context.Context, tx pgx.Tx, transferID uint64, storageID uint) error {
+	q := `WITH arrived AS (
+		INSERT INTO stocks (storage_id, product_id, quantity)
+		SELECT $2, product_id, quantity FROM transfer_lines WHERE transfer_id = $1
+		ON CONFLICT (storage_id, product_id) DO UPDATE SET quantity = stocks.quantity + EXCLUDED.quantity
+	)
+	INSERT INTO stock_movements (movement_type, storage_id, product_id, on_hand_change, transfer_id)
+	SELECT 'transfer', $2, product_id, quantity, transfer_id FROM transfer_lines WHERE transfer_id = $1`
+	_, err := tx.Exec(ctx, q, transferID, storageID)
+	return err
+}
+
+func returnReservations(ctx context.Context, tx pgx.Tx, transfer *models.Transfer) error {
+	// позиция на складе назначения могла быть частично освобождена, возвращается не больше оставшегося
+	q := `SELECT t.reservation_id, t.product_id, LEAST(t.quantity, i.quantity), p.product_code
+		FROM transfer_reservations t
+		JOIN reservations r ON r.reservation_id = t.reservation_id AND r.reservation_status = 'active'
+		JOIN reservation_items i ON i.reservation_id = t.reservation_id
+			AND i.storage_id = $2 AND i.product_id = t.product_id
+		JOIN products p ON p.product_id = t.product_id
+		WHERE t.transfer_id = $1
+		ORDER BY t.reservation_id FOR UPDATE OF r, i`
+	rows, err := tx.Query(ctx, q, transfer.ID, transfer.DestinationStorageID)
+	if err != nil {
+		return err
+	}
+	var item reservedItem
+	var code string
+	items := make([]reservedItem, 0)
+	products := make([]models.Item, 0)
+	_, err = pgx.ForEachRow(rows, []any{&item.reservationID, &item.productID, &item.quantity, &code}, func() error {
+		items = append(items, item)
+		products = append(products, models.Item{Product: models.Product{ID: item.productID, Code: code}})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := lockProducts(ctx, tx, "transfer", products); err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	movements := make([]models.Movement, 0, len(items)*2)
+	for _, item := range items {
+		batch.Queue(moveReservationItemQ, item.reservationID, transfer.DestinationStorageID, transfer.SourceStorageID, item.quantity, item.productID)
+		reservationID := item.reservationID
+		movements = append(movements, models.Movement{
+			Type:           models.MovementTransfer,
+			StorageID:      transfer.DestinationStorageID,
+			ProductID:      item.productID,
+			ReservedChange: -int(item.quantity),
+			ReservationID:  &reservationID,
+			TransferID:     &transfer.ID,
+		}, models.Movement{
+			Type:           models.MovementTransfer,
+			StorageID:      transfer.SourceStorageID,
+			ProductID:      item.productID,
+			ReservedChange: int(item.quantity),
+			ReservationID:  &reservationID,
+			TransferID:     &transfer.ID,
+		})
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return err
+	}
+
+	return recordMovements(ctx, tx, movements)
+}
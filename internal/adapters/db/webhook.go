@@ -0,0 +1,324 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/jackc/pgx/v5"
+)
+
+// dispatchLockKey ключ advisory блокировки, которая оставляет создание доставок одному экземпляру сервиса.
+const dispatchLockKey = 7_405_127
+
+// deliveryColumns колонки доставки в порядке scanDelivery.
+const deliveryColumns = `d.delivery_id, d.webhook_id, d.event_type, d.payload, d.delivery_status, d.attempts,
+	d.next_attempt_at, d.last_status_code, d.last_error, d.created_at, d.delivered_at`
+
+func (r *repository) CreateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error) {
+	ctx, end := startQuery(ctx, "CreateWebhook")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CreateWebhook")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `INSERT INTO webhooks (webhook_url, event_types, webhook_secret) VALUES ($1, $2, $3)
+		RETURNING webhook_id, webhook_url, event_types, created_at`
+	rows, err := r.client.Query(ctx, q, webhook.URL, eventTypes(webhook.Events), webhook.Secret)
+	if err != nil {
+		return nil, err
+	}
+	created, err := pgx.CollectExactlyOneRow(rows, scanWebhook)
+	if err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+func (r *repository) FindWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	ctx, end := startQuery(ctx, "FindWebhooks")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindWebhooks")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `SELECT webhook_id, webhook_url, event_types, created_at FROM webhooks ORDER BY webhook_id`
+	rows, err := r.client.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, scanWebhook)
+}
+
+func (r *repository) FindWebhookViaID(ctx context.Context, webhookID uint) (*models.Webhook, error) {
+	ctx, end := startQuery(ctx, "FindWebhookViaID")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindWebhookViaID")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `SELECT webhook_id, webhook_url, event_types, created_at FROM webhooks WHERE webhook_id = $1`
+	rows, err := r.client.Query(ctx, q, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	webhook, err := pgx.CollectExactlyOneRow(rows, scanWebhook)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrWebhookNotFound
+		}
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+// DeleteWebhook удаляет подписку вместе с журналом её доставок.
+func (r *repository) DeleteWebhook(ctx context.Context, webhookID uint) error {
+	ctx, end := startQuery(ctx, "DeleteWebhook")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start DeleteWebhook")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	tag, err := r.client.Exec(ctx, `DELETE FROM webhooks WHERE webhook_id = $1`, webhookID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// DispatchOutbox передаёт в build до limit событий outbox, по которым ещё не созданы доставки, и создаёт
+// доставки полученных событий всем подписанным на их тип в той же транзакции, в которой события outbox
+// отмечаются разосланными. Как и при публикации, берутся только события завершённых транзакций.
+// Пока другой экземпляр создаёт доставки, возвращает 0 без вызова build.
+func (r *repository) DispatchOutbox(ctx context.Context, limit int, build func([]models.OutboxEvent) ([]models.Event, error)) (int, error) {
+	ctx, end := startQuery(ctx, "DispatchOutbox")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start DispatchOutbox")
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	tx, err := r.client.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var locked bool
+	if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1)`, dispatchLockKey).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	q := `SELECT event_id, event_type, event_key, payload, created_at FROM outbox_events
+		WHERE dispatched_at IS NULL AND xact_id < pg_snapshot_xmin(pg_current_snapshot())
+		ORDER BY event_id LIMIT $1`
+	rows, err := tx.Query(ctx, q, limit)
+	if err != nil {
+		return 0, err
+	}
+	outbox, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.OutboxEvent, error) {
+		var e models.OutboxEvent
+		err := row.Scan(&e.ID, &e.Type, &e.Key, &e.Payload, &e.CreatedAt)
+		return e, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(outbox) == 0 {
+		return 0, nil
+	}
+
+	events, err := build(outbox)
+	if err != nil {
+		return 0, err
+	}
+
+	enqueueQ := `INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+		SELECT webhook_id, $1::text, $2::jsonb FROM webhooks WHERE $1::text = ANY (event_types)`
+	batch := &pgx.Batch{}
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return 0, err
+		}
+		batch.Queue(enqueueQ, string(event.Type), payload)
+	}
+	ids := make([]uint64, 0, len(outbox))
+	for _, event := range outbox {
+		ids = append(ids, event.ID)
+	}
+	batch.Queue(`UPDATE outbox_events SET dispatched_at = now() WHERE event_id = ANY ($1)`, ids)
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return len(outbox), nil
+}
+
+// ClaimDeliveries выбирает доставки, время попытки которых наступило, и откладывает их на lease,
+// чтобы параллельные обработчики их не взяли. Если результат попытки не будет записан,
+// доставка повторится по истечении lease.
+func (r *repository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	ctx, end := startQuery(ctx, "ClaimDeliveries")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start ClaimDeliveries")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `UPDATE webhook_deliveries d SET next_attempt_at = now() + $2::bigint * interval '1 millisecond'
+		FROM webhooks w
+		WHERE w.webhook_id = d.webhook_id AND d.delivery_id IN (
+			SELECT delivery_id FROM webhook_deliveries
+			WHERE delivery_status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at, delivery_id
+			LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + deliveryColumns + `, w.webhook_url, w.webhook_secret`
+	rows, err := r.client.Query(ctx, q, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.WebhookDelivery, error) {
+		var d models.WebhookDelivery
+		err := row.Scan(&d.ID, &d.WebhookID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt, &d.URL, &d.Secret)
+		return d, err
+	})
+}
+
+// UpdateDelivery записывает результат попытки доставки, следующая попытка назначается через retryIn.
+func (r *repository) UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery, retryIn time.Duration) error {
+	ctx, end := startQuery(ctx, "UpdateDelivery")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start UpdateDelivery")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `UPDATE webhook_deliveries SET delivery_status = @status, attempts = @attempts,
+			last_status_code = @statusCode, last_error = @lastError,
+			next_attempt_at = now() + @retryIn::bigint * interval '1 millisecond',
+			delivered_at = CASE WHEN @status = 'delivered' THEN now() END
+		WHERE delivery_id = @deliveryID`
+	_, err := r.client.Exec(ctx, q, pgx.NamedArgs{
+		"deliveryID": delivery.ID,
+		"status":     string(delivery.Status),
+		"attempts":   delivery.Attempts,
+		"statusCode": delivery.LastStatusCode,
+		"lastError":  delivery.LastError,
+		"retryIn":    retryIn.Milliseconds(),
+	})
+
+	return err
+}
+
+// FindDeliveries возвращает журнал доставок, новые идут первыми.
+func (r *repository) FindDeliveries(ctx context.Context, filter models.DeliveryFilter) ([]models.WebhookDelivery, error) {
+	ctx, end := startQuery(ctx, "FindDeliveries")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindDeliveries")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries d
+		WHERE (@webhookID::int = 0 OR d.webhook_id = @webhookID)
+			AND (@status = '' OR d.delivery_status = @status)
+		ORDER BY d.delivery_id DESC LIMIT @limit OFFSET @offset`
+	rows, err := r.client.Query(ctx, q, pgx.NamedArgs{
+		"webhookID": filter.WebhookID,
+		"status":    string(filter.Status),
+		"limit":     filter.Limit,
+		"offset":    filter.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, scanDelivery)
+}
+
+// RetryDelivery возвращает недоставленную доставку в очередь с обнулёнными попытками.
+func (r *repository) RetryDelivery(ctx context.Context, deliveryID uint64) (*models.WebhookDelivery, error) {
+	ctx, end := startQuery(ctx, "RetryDelivery")
+	defer end()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start RetryDelivery")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	q := `UPDATE webhook_deliveries d SET delivery_status = 'pending', attempts = 0, next_attempt_at = now()
+		WHERE d.delivery_id = $1 AND d.delivery_status = 'dead'
+		RETURNING ` + deliveryColumns
+	rows, err := r.client.Query(ctx, q, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := pgx.CollectExactlyOneRow(rows, scanDelivery)
+	if err == nil {
+		return &delivery, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	var exists bool
+	existsQ := `SELECT EXISTS (SELECT 1 FROM webhook_deliveries WHERE delivery_id = $1)`
+	if err := r.client.QueryRow(ctx, existsQ, deliveryID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, models.ErrDeliveryNotFound
+	}
+	return nil, models.ErrDeliveryNotDead
+}
+
+func eventTypes(events []models.EventType) []string {
+	types := make([]string, 0, len(events))
+	for _, eventType := range events {
+		types = append(types, string(eventType))
+	}
+	return types
+}
+
+func scanWebhook(row pgx.CollectableRow) (models.Webhook, error) {
+	var w models.Webhook
+	var types []string
+	if err := row.Scan(&w.ID, &w.URL, &types, &w.CreatedAt); err != nil {
+		return w, err
+	}
+	w.Events = make([]models.EventType, 0, len(types))
+	for _, eventType := range types {
+		w.Events = append(w.Events, models.EventType(eventType))
+	}
+	return w, nil
+}
+
+func scanDelivery(row pgx.CollectableRow) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := row.Scan(&d.ID, &d.WebhookID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+	return d, err
+}
@@ -0,0 +1,171 @@
+// Package exporter построчно записывает остатки склада в форматах CSV, NDJSON и XLSX.
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/xuri/excelize/v2"
+)
+
+var header = []string{"storage_id", "product_id", "code", "name", "size", "on_hand", "reserved", "available"}
+
+var contentTypes = map[models.ExportFormat]string{
+	models.ExportCSV:    "text/csv",
+	models.ExportNDJSON: "application/x-ndjson",
+	models.ExportXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// Writer записывает остатки по одному, до первого вызова Write или Close
+// в нижележащий io.Writer ничего не пишется. Close дописывает буферизованные данные.
+type Writer interface {
+	Write(stock models.Stock) error
+	Close() error
+}
+
+func NewWriter(w io.Writer, format models.ExportFormat) (Writer, error) {
+	switch format {
+	case models.ExportCSV:
+		return &csvWriter{writer: csv.NewWriter(w)}, nil
+	case models.ExportNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	case models.ExportXLSX:
+		return &xlsxWriter{w: w}, nil
+	default:
+		return nil, models.ErrExportFormatNotValid
+	}
+}
+
+func ContentType(format models.ExportFormat) string {
+	return contentTypes[format]
+}
+
+// FormatFromMediaType формат выгрузки по заголовку Accept, пустая строка если формат неизвестен.
+func FormatFromMediaType(mediaType string) models.ExportFormat {
+	for format, contentType := range contentTypes {
+		if contentType == mediaType {
+			return format
+		}
+	}
+	return ""
+}
+
+func record(stock models.Stock) []string {
+	return []string{
+		strconv.FormatUint(uint64(stock.StorageID), 10),
+		strconv.FormatUint(uint64(stock.ProductID), 10),
+		stock.Code,
+		stock.Name,
+		strconv.FormatUint(uint64(stock.Size), 10),
+		strconv.FormatUint(uint64(stock.OnHand), 10),
+		strconv.FormatUint(uint64(stock.Reserved), 10),
+		strconv.FormatUint(uint64(stock.Available), 10),
+	}
+}
+
+type csvWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	return c.writer.Write(header)
+}
+
+func (c *csvWriter) Write(stock models.Stock) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	return c.writer.Write(record(stock))
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(stock models.Stock) error {
+	return n.encoder.Encode(stock)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+// xlsxWriter использует потоковую запись excelize, строки сверх её буфера
+// сбрасываются во временный файл, а не держатся в памяти.
+type xlsxWriter struct {
+	w      io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+const sheet = "Sheet1"
+
+func (x *xlsxWriter) init() error {
+	if x.stream != nil {
+		return nil
+	}
+	x.file = excelize.NewFile()
+	stream, err := x.file.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+	x.stream = stream
+	return x.writeRow(header)
+}
+
+func (x *xlsxWriter) writeRow(values []string) error {
+	x.row++
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+	row := make([]any, len(values))
+	for i, value := range values {
+		row[i] = value
+	}
+	return x.stream.SetRow(cell, row)
+}
+
+func (x *xlsxWriter) Write(stock models.Stock) error {
+	if err := x.init(); err != nil {
+		return err
+	}
+	x.row++
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+	return x.stream.SetRow(cell, []any{
+		stock.StorageID, stock.ProductID, stock.Code, stock.Name,
+		stock.Size, stock.OnHand, stock.Reserved, stock.Available,
+	})
+}
+
+func (x *xlsxWriter) Close() error {
+	if err := x.init(); err != nil {
+		return err
+	}
+	defer x.file.Close()
+	if err := x.stream.Flush(); err != nil {
+		return err
+	}
+	_, err := x.file.WriteTo(x.w)
+	return err
+}
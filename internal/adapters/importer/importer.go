@@ -0,0 +1,153 @@
+// Package importer разбирает файлы импорта товаров в форматах CSV и NDJSON.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+)
+
+var (
+	ErrHeaderNotValid = errors.New("csv header must contain code and name columns")
+	ErrColumnUnknown  = errors.New("unknown csv column")
+)
+
+const maxLineSize = 1 << 20
+
+// Reader возвращает строки файла по одной, ошибки отдельных строк имеют тип *models.RowError,
+// после них чтение можно продолжать. Конец файла обозначается io.EOF.
+type Reader interface {
+	Next() (models.ImportRow, error)
+}
+
+func NewReader(r io.Reader, format models.ImportFormat) (Reader, error) {
+	switch format {
+	case models.ImportCSV:
+		return newCSVReader(r)
+	case models.ImportNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		return &ndjsonReader{scanner: scanner}, nil
+	default:
+		return nil, models.ErrImportFormatNotValid
+	}
+}
+
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		switch column {
+		case "code", "name", "size", "count", "storage":
+			columns[column] = i
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrColumnUnknown, column)
+		}
+	}
+	if _, ok := columns["code"]; !ok {
+		return nil, ErrHeaderNotValid
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, ErrHeaderNotValid
+	}
+
+	return &csvReader{reader: reader, columns: columns}, nil
+}
+
+func (c *csvReader) Next() (models.ImportRow, error) {
+	record, err := c.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return models.ImportRow{}, &models.RowError{Line: parseErr.StartLine, Err: parseErr.Err}
+		}
+		return models.ImportRow{}, err
+	}
+	line, _ := c.reader.FieldPos(0)
+
+	row := models.ImportRow{
+		Line: line,
+		Code: strings.TrimSpace(record[c.columns["code"]]),
+		Name: strings.TrimSpace(record[c.columns["name"]]),
+	}
+	if i, ok := c.columns["size"]; ok {
+		size, err := parseUint(record[i])
+		if err != nil {
+			return row, &models.RowError{Line: line, Err: fmt.Errorf("size: %w", err)}
+		}
+		if size != nil {
+			row.Size = *size
+		}
+	}
+	if i, ok := c.columns["count"]; ok {
+		if row.Count, err = parseUint(record[i]); err != nil {
+			return row, &models.RowError{Line: line, Err: fmt.Errorf("count: %w", err)}
+		}
+	}
+	if i, ok := c.columns["storage"]; ok {
+		if row.Storage, err = parseUint(record[i]); err != nil {
+			return row, &models.RowError{Line: line, Err: fmt.Errorf("storage: %w", err)}
+		}
+	}
+
+	return row, nil
+}
+
+// parseUint возвращает nil для пустого значения.
+func parseUint(s string) (*uint, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	u := uint(v)
+	return &u, nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (n *ndjsonReader) Next() (models.ImportRow, error) {
+	for n.scanner.Scan() {
+		n.line++
+		data := bytes.TrimSpace(n.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		row := models.ImportRow{Line: n.line}
+		if err := json.Unmarshal(data, &row); err != nil {
+			return row, &models.RowError{Line: n.line, Err: err}
+		}
+		return row, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return models.ImportRow{}, err
+	}
+	return models.ImportRow{}, io.EOF
+}
@@ -0,0 +1,115 @@
+// Package notifier доставляет предупреждения о низком остатке в лог, файл или на webhook.
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/usecase"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+)
+
+var (
+	ErrUnknownNotifier = errors.New("unknown alert notifier, expected log, file or webhook")
+	ErrNilWebhookURL   = errors.New("webhook URL is required for webhook notifier")
+)
+
+const webhookTimeout = time.Second * 10
+
+// New создаёт доставку по её названию: log, file (NDJSON в path) или webhook (POST на url).
+func New(kind, path, url string) (usecase.Notifier, error) {
+	switch kind {
+	case "log":
+		return &logNotifier{}, nil
+	case "file":
+		return &fileNotifier{path: path}, nil
+	case "webhook":
+		if url == "" {
+			return nil, ErrNilWebhookURL
+		}
+		return &webhookNotifier{url: url, client: &http.Client{Timeout: webhookTimeout}}, nil
+	default:
+		return nil, ErrUnknownNotifier
+	}
+}
+
+type logNotifier struct{}
+
+func (logNotifier) Notify(ctx context.Context, alerts []models.StockAlert) error {
+	logger := logging.FromContext(ctx)
+	for _, alert := range alerts {
+		logger.Warn().
+			Uint64("alert_id", alert.ID).
+			Str("status", string(alert.Status)).
+			Uint("storage_id", alert.StorageID).
+			Str("code", alert.Code).
+			Uint("available", alert.Available).
+			Uint("threshold", alert.Threshold).
+			Msg("stock alert")
+	}
+	return nil
+}
+
+// fileNotifier дописывает предупреждения в файл по одному JSON объекту на строку.
+type fileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (f *fileNotifier) Notify(_ context.Context, alerts []models.StockAlert) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	for _, alert := range alerts {
+		if err := encoder.Encode(alert); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
+
+// webhookNotifier отправляет предупреждения одним запросом {"alerts": [...]}.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (wh *webhookNotifier) Notify(ctx context.Context, alerts []models.StockAlert) error {
+	body, err := json.Marshal(struct {
+		Alerts []models.StockAlert `json:"alerts"`
+	}{Alerts: alerts})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
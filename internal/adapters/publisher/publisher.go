@@ -0,0 +1,151 @@
+// Package publisher публикует события outbox в NDJSON поток (stdout или файл)
+// или в Kafka через HTTP API, совместимый с Kafka REST Proxy v2.
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/usecase"
+)
+
+var (
+	ErrUnknownPublisher = errors.New("unknown outbox publisher, expected stdout, file or kafka")
+	ErrNilKafkaURL      = errors.New("kafka REST proxy URL and topic are required for kafka publisher")
+)
+
+const kafkaTimeout = time.Second * 10
+
+// New создаёт публикацию по её названию: stdout, file (NDJSON в path) или kafka (topic через REST Proxy по kafkaURL).
+func New(kind, path, kafkaURL, topic string) (usecase.Publisher, error) {
+	switch kind {
+	case "stdout":
+		return &streamPublisher{w: os.Stdout}, nil
+	case "file":
+		return &filePublisher{path: path}, nil
+	case "kafka":
+		if kafkaURL == "" || topic == "" {
+			return nil, ErrNilKafkaURL
+		}
+		return &kafkaPublisher{
+			url:    strings.TrimRight(kafkaURL, "/") + "/topics/" + url.PathEscape(topic),
+			client: &http.Client{Timeout: kafkaTimeout},
+		}, nil
+	default:
+		return nil, ErrUnknownPublisher
+	}
+}
+
+// streamPublisher пишет события по одному JSON объекту на строку.
+type streamPublisher struct {
+	w io.Writer
+}
+
+func (s *streamPublisher) Publish(_ context.Context, events []models.OutboxEvent) error {
+	// пачка пишется одним вызовом, чтобы строки не перемешались с другим выводом
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+type filePublisher struct {
+	path string
+}
+
+func (f *filePublisher) Publish(ctx context.Context, events []models.OutboxEvent) error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := (&streamPublisher{w: file}).Publish(ctx, events); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// kafkaPublisher отправляет пачку событий одним запросом, ключ записи - ключ события,
+// поэтому события одного товара попадают в одну партицию.
+type kafkaPublisher struct {
+	url    string
+	client *http.Client
+}
+
+type kafkaRecord struct {
+	Key   string             `json:"key"`
+	Value models.OutboxEvent `json:"value"`
+}
+
+type kafkaResponse struct {
+	Offsets []struct {
+		Partition int     `json:"partition"`
+		Offset    int64   `json:"offset"`
+		ErrorCode *int    `json:"error_code"`
+		Error     *string `json:"error"`
+	} `json:"offsets"`
+}
+
+func (k *kafkaPublisher) Publish(ctx context.Context, events []models.OutboxEvent) error {
+	records := make([]kafkaRecord, 0, len(events))
+	for _, event := range events {
+		records = append(records, kafkaRecord{Key: event.Key, Value: event})
+	}
+	body, err := json.Marshal(struct {
+		Records []kafkaRecord `json:"records"`
+	}{Records: records})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("kafka REST proxy responded with status %d", resp.StatusCode)
+	}
+
+	// прокси отвечает 200 и при ошибке записи отдельных событий
+	var result kafkaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decode kafka REST proxy response: %w", err)
+	}
+	if len(result.Offsets) != len(records) {
+		return fmt.Errorf("kafka REST proxy acknowledged %d of %d records", len(result.Offsets), len(records))
+	}
+	for i, offset := range result.Offsets {
+		if offset.ErrorCode != nil || offset.Error != nil {
+			reason := ""
+			if offset.Error != nil {
+				reason = *offset.Error
+			}
+			return fmt.Errorf("kafka REST proxy rejected event %d: %s", events[i].ID, reason)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+)
+
+// kafkaStub принимает запросы как Kafka REST Proxy v2 и отвечает respond на каждую пачку.
+func kafkaStub(t *testing.T, respond func(w http.ResponseWriter, records []kafkaRecord)) (*httptest.Server, *[]kafkaRecord) {
+	t.Helper()
+	var received []kafkaRecord
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/topics/stock-events" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/vnd.kafka.json.v2+json" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		var body struct {
+			Records []kafkaRecord `json:"records"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode records: %v", err)
+		}
+		received = append(received, body.Records...)
+		respond(w, body.Records)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &received
+}
+
+func acknowledge(w http.ResponseWriter, records []kafkaRecord) {
+	offsets := make([]string, 0, len(records))
+	for i := range records {
+		offsets = append(offsets, fmt.Sprintf(`{"partition": 0, "offset": %d}`, i))
+	}
+	fmt.Fprintf(w, `{"offsets": [%s]}`, strings.Join(offsets, ","))
+}
+
+func testEvents() []models.OutboxEvent {
+	return []models.OutboxEvent{
+		{ID: 1, Type: "stock.reservation", Key: "7", Payload: json.RawMessage(`{"product_id": 7}`)},
+		{ID: 2, Type: "stock.release", Key: "9", Payload: json.RawMessage(`{"product_id": 9}`)},
+	}
+}
+
+func TestKafkaPublisher(t *testing.T) {
+	srv, received := kafkaStub(t, acknowledge)
+	p, err := New("kafka", "", srv.URL+"/", "stock-events")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Publish(context.Background(), testEvents()); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if len(*received) != 2 {
+		t.Fatalf("received %d records, want 2", len(*received))
+	}
+	for i, record := range *received {
+		want := testEvents()[i]
+		if record.Key != want.Key || record.Value.ID != want.ID || record.Value.Type != want.Type {
+			t.Errorf("record %d = %+v, want key %s and event %d", i, record, want.Key, want.ID)
+		}
+	}
+}
+
+func TestKafkaPublisherFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(w http.ResponseWriter, records []kafkaRecord)
+	}{
+		{
+			name: "status",
+			respond: func(w http.ResponseWriter, _ []kafkaRecord) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+		},
+		{
+			name: "partial acknowledgement",
+			respond: func(w http.ResponseWriter, _ []kafkaRecord) {
+				fmt.Fprint(w, `{"offsets": [{"partition": 0, "offset": 1}]}`)
+			},
+		},
+		{
+			name: "record error",
+			respond: func(w http.ResponseWriter, _ []kafkaRecord) {
+				fmt.Fprint(w, `{"offsets": [{"partition": 0, "offset": 1},
+					{"partition": null, "offset": null, "error_code": 50002, "error": "leader not available"}]}`)
+			},
+		},
+		{
+			name: "malformed response",
+			respond: func(w http.ResponseWriter, _ []kafkaRecord) {
+				fmt.Fprint(w, `not json`)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := kafkaStub(t, tt.respond)
+			p, err := New("kafka", "", srv.URL, "stock-events")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Publish(context.Background(), testEvents()); err == nil {
+				t.Fatal("publish succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestNewKafkaRequiresURL(t *testing.T) {
+	if _, err := New("kafka", "", "", "stock-events"); err != ErrNilKafkaURL {
+		t.Fatalf("err = %v, want %v", err, ErrNilKafkaURL)
+	}
+}
@@ -0,0 +1,69 @@
+// Package webhook отправляет подписчикам доставки событий, подписанные HMAC-SHA256.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+)
+
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+type Sender struct {
+	client *http.Client
+}
+
+func NewSender(timeout time.Duration) *Sender {
+	return &Sender{client: &http.Client{Timeout: timeout}}
+}
+
+// Send отправляет доставку и возвращает код ответа подписчика, 0 если ответ не получен.
+// Ответ вне 2xx считается ошибкой.
+func (s *Sender) Send(ctx context.Context, delivery models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(delivery.EventType))
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// тело дочитывается, чтобы соединение вернулось в пул
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign подпись доставки: "sha256=" и hex HMAC-SHA256 секрета от строки "<timestamp>.<body>".
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
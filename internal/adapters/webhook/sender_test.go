@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+)
+
+func TestSign(t *testing.T) {
+	got := Sign("s3cr3t", 1700000000, []byte(`{"type":"stock.changed"}`))
+	want := "sha256=30f100436ee93e64b8149e73220dca88fc44dbe2fa287a0a9cfa8ef5cd107b18"
+	if got != want {
+		t.Fatalf("Sign() = %s, want %s", got, want)
+	}
+	if other := Sign("other", 1700000000, []byte(`{"type":"stock.changed"}`)); other == want {
+		t.Fatal("signature does not depend on secret")
+	}
+	if other := Sign("s3cr3t", 1700000001, []byte(`{"type":"stock.changed"}`)); other == want {
+		t.Fatal("signature does not depend on timestamp")
+	}
+}
+
+func TestSenderSend(t *testing.T) {
+	payload := []byte(`{"type":"reservation.expired","data":{"reservation_id":15}}`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+		if string(body) != string(payload) {
+			t.Errorf("body = %s, want %s", body, payload)
+		}
+		if got := r.Header.Get(HeaderEvent); got != string(models.EventReservationExpired) {
+			t.Errorf("%s = %q", HeaderEvent, got)
+		}
+		if got := r.Header.Get(HeaderDelivery); got != "42" {
+			t.Errorf("%s = %q, want 42", HeaderDelivery, got)
+		}
+		// подписчик проверяет подпись так же, как описано в README
+		timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil {
+			t.Errorf("%s: %v", HeaderTimestamp, err)
+		}
+		if got, want := r.Header.Get(HeaderSignature), Sign("s3cr3t", timestamp, body); got != want {
+			t.Errorf("%s = %q, want %q", HeaderSignature, got, want)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	statusCode, err := NewSender(time.Second).Send(context.Background(), models.WebhookDelivery{
+		ID:        42,
+		EventType: models.EventReservationExpired,
+		Payload:   payload,
+		URL:       srv.URL,
+		Secret:    "s3cr3t",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if statusCode != http.StatusNoContent {
+		t.Fatalf("Send() status = %d, want %d", statusCode, http.StatusNoContent)
+	}
+}
+
+func TestSenderSendFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	statusCode, err := NewSender(time.Second).Send(context.Background(), models.WebhookDelivery{URL: srv.URL, Payload: []byte(`{}`)})
+	if err == nil {
+		t.Fatal("Send() succeeded on 503")
+	}
+	if statusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Send() status = %d, want %d", statusCode, http.StatusServiceUnavailable)
+	}
+
+	srv.Close()
+	statusCode, err = NewSender(time.Second).Send(context.Background(), models.WebhookDelivery{URL: srv.URL, Payload: []byte(`{}`)})
+	if err == nil || statusCode != 0 {
+		t.Fatalf("Send() to closed server = %d, %v, want 0 and error", statusCode, err)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"sync"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -30,6 +31,41 @@ type service struct {
 	Address          string `env:"ADDRESS"`
 	MigrationVersion uint   `env:"MIGRATION_VERSION"`
 	MigrationsPath   string `env:"MIGRATIONS_PATH"`
+
+	ShutdownDelay   time.Duration `env:"SHUTDOWN_DELAY" env-default:"5s"`
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" env-default:"30s"`
+
+	ReservationTTL     time.Duration `env:"RESERVATION_TTL"`
+	AllocationStrategy string        `env:"ALLOCATION_STRATEGY" env-default:"single"`
+	ExpirationInterval time.Duration `env:"EXPIRATION_INTERVAL" env-default:"30s"`
+
+	IdempotencyRetention       time.Duration `env:"IDEMPOTENCY_RETENTION" env-default:"24h"`
+	IdempotencyLease           time.Duration `env:"IDEMPOTENCY_LEASE" env-default:"1m"`
+	IdempotencyCleanupInterval time.Duration `env:"IDEMPOTENCY_CLEANUP_INTERVAL" env-default:"1m"`
+
+	ImportBatchSize int `env:"IMPORT_BATCH_SIZE" env-default:"500"`
+
+	AlertInterval   time.Duration `env:"ALERT_INTERVAL" env-default:"1m"`
+	AlertNotifier   string        `env:"ALERT_NOTIFIER" env-default:"log"`
+	AlertFile       string        `env:"ALERT_FILE" env-default:"alerts.ndjson"`
+	AlertWebhookURL string        `env:"ALERT_WEBHOOK_URL"`
+
+	WebhookInterval    time.Duration `env:"WEBHOOK_INTERVAL" env-default:"1s"`
+	WebhookTimeout     time.Duration `env:"WEBHOOK_TIMEOUT" env-default:"10s"`
+	WebhookBackoff     time.Duration `env:"WEBHOOK_BACKOFF" env-default:"5s"`
+	WebhookMaxAttempts int           `env:"WEBHOOK_MAX_ATTEMPTS" env-default:"8"`
+
+	OutboxInterval   time.Duration `env:"OUTBOX_INTERVAL" env-default:"1s"`
+	OutboxBatchSize  int           `env:"OUTBOX_BATCH_SIZE" env-default:"100"`
+	OutboxPublisher  string        `env:"OUTBOX_PUBLISHER" env-default:"stdout"`
+	OutboxFile       string        `env:"OUTBOX_FILE" env-default:"outbox.ndjson"`
+	OutboxKafkaURL   string        `env:"OUTBOX_KAFKA_URL"`
+	OutboxKafkaTopic string        `env:"OUTBOX_KAFKA_TOPIC" env-default:"stock-events"`
+
+	TraceExporter     string  `env:"TRACE_EXPORTER" env-default:"none"`
+	TraceFile         string  `env:"TRACE_FILE" env-default:"traces.ndjson"`
+	TraceOTLPEndpoint string  `env:"TRACE_OTLP_ENDPOINT"`
+	TraceSampleRatio  float64 `env:"TRACE_SAMPLE_RATIO" env-default:"1"`
 }
 
 var (
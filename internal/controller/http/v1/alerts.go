@@ -0,0 +1,158 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+)
+
+type AlertUsecase interface {
+	SetThreshold(ctx context.Context, code string, threshold models.Threshold) (*models.Threshold, error)
+	GetThresholds(ctx context.Context, code string) ([]models.Threshold, error)
+	DeleteThreshold(ctx context.Context, code string, storageID *uint) error
+	GetAlerts(ctx context.Context, filter models.AlertFilter) ([]models.StockAlert, error)
+}
+
+// alertServer обработчики порогов пополнения и предупреждений о низком остатке.
+type alertServer struct {
+	alertUC AlertUsecase
+}
+
+func NewAlertServer(auc AlertUsecase) *alertServer {
+	return &alertServer{alertUC: auc}
+}
+
+// SetThresholdHandler устанавливает порог товара: {"storage_id": 1, "threshold": 10},
+// без storage_id порог действует на всех складах.
+func (s *alertServer) SetThresholdHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	var threshold models.Threshold
+	if err := json.NewDecoder(r.Body).Decode(&threshold); err != nil {
+		responder.sendResponse(http.StatusUnprocessableEntity, "unable to deserialize the request body", err)
+		return
+	}
+
+	updated, err := s.alertUC.SetThreshold(ctx, r.PathValue("code"), threshold)
+	if err != nil {
+		responder.sendAlertError(err, "setting threshold of product ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"threshold successful set",
+		nil,
+		responseOption("threshold", updated),
+	)
+}
+
+func (s *alertServer) GetThresholdsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	thresholds, err := s.alertUC.GetThresholds(ctx, r.PathValue("code"))
+	if err != nil {
+		responder.sendAlertError(err, "getting thresholds of product ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"successful getting thresholds of product",
+		nil,
+		responseOption("thresholds", thresholds),
+	)
+}
+
+// DeleteThresholdHandler удаляет порог склада из параметра storage_id или общий порог товара.
+func (s *alertServer) DeleteThresholdHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	var storageID *uint
+	if v := r.URL.Query().Get("storage_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 0)
+		if err != nil {
+			responder.sendResponse(http.StatusBadRequest, "can't delete threshold of product", ErrStorageIDNotValid)
+			return
+		}
+		storage := uint(id)
+		storageID = &storage
+	}
+
+	if err := s.alertUC.DeleteThreshold(ctx, r.PathValue("code"), storageID); err != nil {
+		responder.sendAlertError(err, "deletion of threshold ended with error")
+		return
+	}
+
+	responder.sendResponse(http.StatusOK, "threshold successful deleted", nil)
+}
+
+// GetAlertsHandler предупреждения о низком остатке, отбор по параметрам status, storage_id и code.
+func (s *alertServer) GetAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	query := r.URL.Query()
+	filter := models.AlertFilter{
+		Status: models.AlertStatus(query.Get("status")),
+		Code:   query.Get("code"),
+	}
+	if v := query.Get("storage_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 0)
+		if err != nil {
+			responder.sendResponse(http.StatusBadRequest, "can't get alerts", ErrStorageIDNotValid)
+			return
+		}
+		filter.StorageID = uint(id)
+	}
+	if err := parsePage(r, &filter.Limit, &filter.Offset); err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't get alerts", err)
+		return
+	}
+
+	alerts, err := s.alertUC.GetAlerts(ctx, filter)
+	if err != nil {
+		responder.sendAlertError(err, "getting alerts ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"successful getting alerts",
+		nil,
+		responseOption("alerts", alerts),
+	)
+}
+
+// sendAlertError сопоставляет ошибки порогов и предупреждений с кодами ответа.
+func (r *responder) sendAlertError(err error, msg string) {
+	switch {
+	case errors.Is(err, models.ErrProductNotFound):
+		r.sendResponse(http.StatusNotFound, msg, models.ErrProductNotFound)
+	case errors.Is(err, models.ErrStorageNotFound):
+		r.sendResponse(http.StatusNotFound, msg, models.ErrStorageNotFound)
+	case errors.Is(err, models.ErrThresholdNotFound):
+		r.sendResponse(http.StatusNotFound, msg, models.ErrThresholdNotFound)
+	case errors.Is(err, models.ErrPageNotValid):
+		r.sendResponse(http.StatusBadRequest, msg, models.ErrPageNotValid)
+	case errors.Is(err, models.ErrAlertStatusNotValid):
+		r.sendResponse(http.StatusBadRequest, msg, models.ErrAlertStatusNotValid)
+	case errors.Is(err, models.ErrThresholdNotValid):
+		r.sendResponse(http.StatusUnprocessableEntity, msg, models.ErrThresholdNotValid)
+	default:
+		logging.FromContext(r.ctx).Error().Err(err).Msg(msg)
+		r.sendResponse(http.StatusInternalServerError, msg, err)
+	}
+}
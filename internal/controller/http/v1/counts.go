@@ -0,0 +1,193 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+)
+
+var ErrCountIDNotValid = errors.New("inventory count ID can only be an unsigned integer type")
+
+type CountUsecase interface {
+	OpenCount(ctx context.Context, storageID uint) (*models.InventoryCount, error)
+	GetCount(ctx context.Context, countID uint64) (*models.InventoryCount, error)
+	SubmitCount(ctx context.Context, countID uint64, lines []models.CountLine) (*models.InventoryCount, []string, error)
+	CommitCount(ctx context.Context, countID uint64) (*models.InventoryCount, error)
+	CancelCount(ctx context.Context, countID uint64) (*models.InventoryCount, error)
+}
+
+// countServer обработчики пересчёта остатков.
+type countServer struct {
+	countUC CountUsecase
+}
+
+func NewCountServer(cuc CountUsecase) *countServer {
+	return &countServer{countUC: cuc}
+}
+
+// OpenCountHandler открывает пересчёт склада: {"storage_id": 1}.
+func (s *countServer) OpenCountHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	var request struct {
+		StorageID uint `json:"storage_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responder.sendResponse(http.StatusUnprocessableEntity, "unable to deserialize the request body", err)
+		return
+	}
+
+	count, err := s.countUC.OpenCount(ctx, request.StorageID)
+	if err != nil {
+		responder.sendCountError(err, nil, "opening of inventory count ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusCreated,
+		"inventory count successful opened",
+		nil,
+		responseOption("count", count),
+	)
+}
+
+// GetCountHandler возвращает пересчёт с расхождениями относительно остатков склада.
+func (s *countServer) GetCountHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	countID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't get inventory count", ErrCountIDNotValid)
+		return
+	}
+
+	count, err := s.countUC.GetCount(ctx, countID)
+	if err != nil {
+		responder.sendCountError(err, nil, "getting inventory count ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"successful getting inventory count",
+		nil,
+		responseOption("count", count),
+	)
+}
+
+// SubmitCountHandler сохраняет фактическое количество товаров: [{"code": "LK-7", "counted": 15}].
+func (s *countServer) SubmitCountHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	countID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't submit inventory count", ErrCountIDNotValid)
+		return
+	}
+
+	var lines []models.CountLine
+	if err := json.NewDecoder(r.Body).Decode(&lines); err != nil {
+		responder.sendResponse(http.StatusUnprocessableEntity, "unable to deserialize the request body", err)
+		return
+	}
+
+	count, notFound, err := s.countUC.SubmitCount(ctx, countID, lines)
+	if err != nil {
+		responder.sendCountError(err, notFound, "submitting of inventory count ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"inventory count successful submitted",
+		nil,
+		responseOption("count", count),
+	)
+}
+
+func (s *countServer) CommitCountHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	countID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't commit inventory count", ErrCountIDNotValid)
+		return
+	}
+
+	count, err := s.countUC.CommitCount(ctx, countID)
+	if err != nil {
+		responder.sendCountError(err, nil, "commit of inventory count ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"inventory count successful committed",
+		nil,
+		responseOption("count", count),
+	)
+}
+
+func (s *countServer) CancelCountHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	countID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't cancel inventory count", ErrCountIDNotValid)
+		return
+	}
+
+	count, err := s.countUC.CancelCount(ctx, countID)
+	if err != nil {
+		responder.sendCountError(err, nil, "cancellation of inventory count ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"inventory count successful cancelled",
+		nil,
+		responseOption("count", count),
+	)
+}
+
+// sendCountError сопоставляет ошибки пересчёта с кодами ответа.
+func (r *responder) sendCountError(err error, notFound []string, msg string) {
+	var conflict *models.CountConflictError
+	switch {
+	case errors.Is(err, models.ErrCountNotFound):
+		r.sendResponse(http.StatusNotFound, msg, models.ErrCountNotFound)
+	case errors.Is(err, models.ErrStorageNotFound):
+		r.sendResponse(http.StatusNotFound, msg, models.ErrStorageNotFound)
+	case errors.Is(err, models.ErrCountNotOpen):
+		r.sendResponse(http.StatusConflict, msg, models.ErrCountNotOpen)
+	case errors.As(err, &conflict):
+		r.sendResponse(http.StatusConflict, msg, models.ErrCountedBelowReserved, responseOption("conflicts", conflict.Lines))
+	case errors.Is(err, models.ErrProductsNotFound):
+		r.sendResponse(http.StatusUnprocessableEntity, msg, models.ErrProductsNotFound, responseOption("not_found", notFound))
+	case errors.Is(err, models.ErrNilStorageID), errors.Is(err, models.ErrCountLinesEmpty),
+		errors.Is(err, models.ErrCodeNotValid), errors.Is(err, models.ErrCountedRequired):
+		r.sendResponse(http.StatusUnprocessableEntity, msg, err)
+	default:
+		if r.sendInUseError(err) {
+			return
+		}
+		logging.FromContext(r.ctx).Error().Err(err).Msg(msg)
+		r.sendResponse(http.StatusInternalServerError, msg, err)
+	}
+}
@@ -0,0 +1,98 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/adapters/exporter"
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+)
+
+// ExportStockHandler выгружает остатки склада, по умолчанию в CSV.
+func (s *server) ExportStockHandler(w http.ResponseWriter, r *http.Request) {
+	responder := &responder{w: w, ctx: r.Context()}
+
+	storageID, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		responder.sendResponse(http.StatusBadRequest, "can't export stock of storage", ErrStorageIDNotValid)
+		return
+	}
+
+	format, err := exportFormat(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		responder.sendResponse(http.StatusBadRequest, "can't export stock of storage", err)
+		return
+	}
+	if format == "" {
+		format = models.ExportCSV
+	}
+
+	s.exportStock(r.Context(), w, uint(storageID), format)
+}
+
+// exportStock пишет остатки в ответ по мере чтения из базы. Если ошибка произошла
+// после начала записи, соединение обрывается, чтобы клиент не принял выгрузку за полную.
+func (s *server) exportStock(ctx context.Context, w http.ResponseWriter, storageID uint, format models.ExportFormat) {
+	logger := logging.FromContext(ctx)
+	responder := &responder{w: w, ctx: ctx}
+
+	writer, err := exporter.NewWriter(w, format)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		responder.sendResponse(http.StatusBadRequest, "can't export stock of storage", err)
+		return
+	}
+	w.Header().Set("Content-Type", exporter.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="storage-%d.%s"`, storageID, format))
+
+	var written bool
+	err = s.receivingUC.ExportStock(ctx, storageID, func(stock models.Stock) error {
+		written = true
+		return writer.Write(stock)
+	})
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		return
+	}
+
+	if written {
+		logger.Error().Err(err).Uint("storage_id", storageID).Msg("export of stock interrupted")
+		panic(http.ErrAbortHandler)
+	}
+	w.Header().Del("Content-Disposition")
+	w.Header().Set("Content-Type", "application/json")
+	if errors.Is(err, models.ErrStorageNotFound) {
+		responder.sendResponse(http.StatusNotFound, "can't export stock of storage", models.ErrStorageNotFound)
+		return
+	}
+	logger.Error().Err(err).Msg("export of stock failed")
+	responder.sendResponse(http.StatusInternalServerError, "export of stock ended with error", err)
+}
+
+// exportFormat формат выгрузки из параметра format или заголовка Accept,
+// пустой, если клиент не запросил выгрузку.
+func exportFormat(r *http.Request) (models.ExportFormat, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return models.ParseExportFormat(format)
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		if format := exporter.FormatFromMediaType(mediaType); format != "" {
+			return format, nil
+		}
+	}
+	return "", nil
+}
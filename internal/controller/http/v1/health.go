@@ -0,0 +1,61 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+)
+
+type HealthUsecase interface {
+	Ready(ctx context.Context) models.Readiness
+	Status(ctx context.Context) models.ServiceStatus
+}
+
+type healthServer struct {
+	healthUC HealthUsecase
+}
+
+func NewHealthServer(huc HealthUsecase) *healthServer {
+	return &healthServer{
+		healthUC: huc,
+	}
+}
+
+// LivenessHandler отвечает, пока процесс способен обрабатывать запросы, бд не проверяется.
+func (s *healthServer) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	responder := &responder{w: w, ctx: r.Context()}
+
+	responder.sendResponse(http.StatusOK, "service is alive", nil)
+}
+
+// ReadinessHandler возвращает 503, если сервис не готов принимать трафик.
+func (s *healthServer) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	readiness := s.healthUC.Ready(ctx)
+	if !readiness.Ready {
+		responder.sendResponse(http.StatusServiceUnavailable, "service is not ready", nil,
+			responseOption("checks", readiness.Checks),
+		)
+		return
+	}
+
+	responder.sendResponse(http.StatusOK, "service is ready", nil,
+		responseOption("checks", readiness.Checks),
+	)
+}
+
+// StatusHandler возвращает подробное состояние сервиса, код ответа не зависит от готовности.
+func (s *healthServer) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	responder.sendResponse(http.StatusOK, "status of service", nil,
+		responseOption("service", s.healthUC.Status(ctx)),
+	)
+}
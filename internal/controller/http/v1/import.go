@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/adapters/importer"
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/usecase"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+)
+
+// maxImportBodySize ограничение размера тела запроса импорта, большие файлы загружаются командой import.
+const maxImportBodySize = 64 << 20
+
+var ErrImportBodyTooLarge = errors.New("import body must not exceed 64 MiB")
+
+type ImportUsecase interface {
+	Import(ctx context.Context, reader usecase.RowReader) (*models.ImportReport, error)
+}
+
+// ImportProductsHandler загружает каталог и остатки из тела запроса,
+// формат задаётся параметром format или заголовком Content-Type.
+func (s *productServer) ImportProductsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	logger := logging.FromContext(r.Context())
+	responder := &responder{w: w, ctx: ctx}
+
+	format, err := models.ParseImportFormat(importFormat(r))
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't import products", err)
+		return
+	}
+
+	var tooLarge *http.MaxBytesError
+	reader, err := importer.NewReader(http.MaxBytesReader(w, r.Body, maxImportBodySize), format)
+	if errors.As(err, &tooLarge) {
+		responder.sendResponse(http.StatusRequestEntityTooLarge, "can't import products", ErrImportBodyTooLarge)
+		return
+	}
+	if err != nil {
+		responder.sendResponse(http.StatusUnprocessableEntity, "can't import products", err)
+		return
+	}
+
+	report, err := s.importUC.Import(ctx, reader)
+	// пачки, загруженные до превышения размера, сохраняются и учтены в отчёте
+	if errors.As(err, &tooLarge) {
+		responder.sendResponse(
+			http.StatusRequestEntityTooLarge,
+			"import of products stopped",
+			ErrImportBodyTooLarge,
+			responseOption("report", report),
+		)
+		return
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("import products failed")
+		responder.sendResponse(
+			http.StatusInternalServerError,
+			"import of products ended with error",
+			err,
+			responseOption("report", report),
+		)
+		return
+	}
+
+	if report.Failed > 0 {
+		responder.sendResponse(
+			http.StatusMultiStatus,
+			"import of products completed with errors",
+			models.ErrRowsNotImported,
+			responseOption("report", report),
+		)
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"import of products successful complete",
+		nil,
+		responseOption("report", report),
+	)
+}
+
+func importFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return string(models.ImportCSV)
+	case "application/x-ndjson", "application/jsonl":
+		return string(models.ImportNDJSON)
+	}
+	return ""
+}
@@ -0,0 +1,146 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+)
+
+var ErrInboundIDNotValid = errors.New("inbound ID can only be an unsigned integer type")
+
+type InboundUsecase interface {
+	RegisterInbound(ctx context.Context, shipment models.Inbound) (*models.Inbound, []string, error)
+	GetInbound(ctx context.Context, inboundID uint64) (*models.Inbound, error)
+	ReceiveInbound(ctx context.Context, inboundID uint64, lines []models.InboundLine) (*models.Inbound, []string, error)
+}
+
+// inboundServer обработчики приёмки поставок.
+type inboundServer struct {
+	inboundUC InboundUsecase
+}
+
+func NewInboundServer(iuc InboundUsecase) *inboundServer {
+	return &inboundServer{inboundUC: iuc}
+}
+
+// RegisterInboundHandler регистрирует поставку:
+// {"storage_id": 1, "reference": "PO-1", "lines": [{"code": "LK-7", "expected": 10}]}.
+func (s *inboundServer) RegisterInboundHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	var shipment models.Inbound
+	if err := json.NewDecoder(r.Body).Decode(&shipment); err != nil {
+		responder.sendResponse(http.StatusUnprocessableEntity, "unable to deserialize the request body", err)
+		return
+	}
+
+	created, notFound, err := s.inboundUC.RegisterInbound(ctx, shipment)
+	if err != nil {
+		responder.sendInboundError(err, notFound, "registration of inbound shipment ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusCreated,
+		"inbound shipment successful registered",
+		nil,
+		responseOption("inbound", created),
+	)
+}
+
+func (s *inboundServer) GetInboundHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	inboundID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't get inbound shipment", ErrInboundIDNotValid)
+		return
+	}
+
+	shipment, err := s.inboundUC.GetInbound(ctx, inboundID)
+	if err != nil {
+		responder.sendInboundError(err, nil, "getting inbound shipment ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"successful getting inbound shipment",
+		nil,
+		responseOption("inbound", shipment),
+	)
+}
+
+// ReceiveInboundHandler принимает поставку с фактическим количеством: [{"code": "LK-7", "received": 9}].
+// Строки, принятые не в ожидаемом количестве, перечисляются в discrepancies.
+func (s *inboundServer) ReceiveInboundHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	inboundID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't receive inbound shipment", ErrInboundIDNotValid)
+		return
+	}
+
+	var lines []models.InboundLine
+	if err := json.NewDecoder(r.Body).Decode(&lines); err != nil {
+		responder.sendResponse(http.StatusUnprocessableEntity, "unable to deserialize the request body", err)
+		return
+	}
+
+	received, notFound, err := s.inboundUC.ReceiveInbound(ctx, inboundID, lines)
+	if err != nil {
+		responder.sendInboundError(err, notFound, "receiving of inbound shipment ended with error")
+		return
+	}
+
+	discrepancies := make([]models.InboundLine, 0)
+	for _, line := range received.Lines {
+		if line.Result != models.ReceiptMatched {
+			discrepancies = append(discrepancies, line)
+		}
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"inbound shipment successful received",
+		nil,
+		responseOption("inbound", received),
+		responseOption("discrepancies", discrepancies),
+	)
+}
+
+// sendInboundError сопоставляет ошибки приёмки с кодами ответа.
+func (r *responder) sendInboundError(err error, notFound []string, msg string) {
+	switch {
+	case errors.Is(err, models.ErrInboundNotFound):
+		r.sendResponse(http.StatusNotFound, msg, models.ErrInboundNotFound)
+	case errors.Is(err, models.ErrStorageNotFound):
+		r.sendResponse(http.StatusNotFound, msg, models.ErrStorageNotFound)
+	case errors.Is(err, models.ErrInboundAlreadyReceived):
+		r.sendResponse(http.StatusConflict, msg, models.ErrInboundAlreadyReceived)
+	case errors.Is(err, models.ErrProductsNotFound):
+		r.sendResponse(http.StatusUnprocessableEntity, msg, models.ErrProductsNotFound, responseOption("not_found", notFound))
+	case errors.Is(err, models.ErrNilStorageID), errors.Is(err, models.ErrInboundLinesEmpty),
+		errors.Is(err, models.ErrCodeNotValid), errors.Is(err, models.ErrExpectedNotValid),
+		errors.Is(err, models.ErrReceivedRequired):
+		r.sendResponse(http.StatusUnprocessableEntity, msg, err)
+	default:
+		if r.sendInUseError(err) {
+			return
+		}
+		logging.FromContext(r.ctx).Error().Err(err).Msg(msg)
+		r.sendResponse(http.StatusInternalServerError, msg, err)
+	}
+}
@@ -0,0 +1,216 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+)
+
+type ProductUsecase interface {
+	CreateProduct(ctx context.Context, patch models.ProductPatch) (*models.Product, error)
+	GetProducts(ctx context.Context, limit, offset int) ([]models.Product, error)
+	GetProduct(ctx context.Context, code string) (*models.Product, error)
+	UpdateProduct(ctx context.Context, code string, patch models.ProductPatch) (*models.Product, error)
+	DeleteProduct(ctx context.Context, code string) error
+	GetMovements(ctx context.Context, code string, filter models.MovementFilter) ([]models.Movement, error)
+}
+
+// productServer обработчики каталога товаров.
+type productServer struct {
+	productUC ProductUsecase
+	importUC  ImportUsecase
+}
+
+func NewProductServer(puc ProductUsecase, iuc ImportUsecase) *productServer {
+	return &productServer{
+		productUC: puc,
+		importUC:  iuc,
+	}
+}
+
+func (s *productServer) CreateProductHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	var patch models.ProductPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		responder.sendResponse(http.StatusUnprocessableEntity, "unable to deserialize the request body", err)
+		return
+	}
+
+	product, err := s.productUC.CreateProduct(ctx, patch)
+	if err != nil {
+		responder.sendProductError(err, "creation of product ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusCreated,
+		"product successful created",
+		nil,
+		responseOption("product", product),
+	)
+}
+
+func (s *productServer) GetProductsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	var limit, offset int
+	var err error
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			responder.sendResponse(http.StatusBadRequest, "can't get products", models.ErrPageNotValid)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil {
+			responder.sendResponse(http.StatusBadRequest, "can't get products", models.ErrPageNotValid)
+			return
+		}
+	}
+
+	products, err := s.productUC.GetProducts(ctx, limit, offset)
+	if err != nil {
+		responder.sendProductError(err, "getting products ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"successful getting products",
+		nil,
+		responseOption("products", products),
+	)
+}
+
+func (s *productServer) GetProductHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	product, err := s.productUC.GetProduct(ctx, r.PathValue("code"))
+	if err != nil {
+		responder.sendProductError(err, "getting product ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"successful getting product",
+		nil,
+		responseOption("product", product),
+	)
+}
+
+func (s *productServer) UpdateProductHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	var patch models.ProductPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		responder.sendResponse(http.StatusUnprocessableEntity, "unable to deserialize the request body", err)
+		return
+	}
+
+	product, err := s.productUC.UpdateProduct(ctx, r.PathValue("code"), patch)
+	if err != nil {
+		responder.sendProductError(err, "update of product ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"product successful updated",
+		nil,
+		responseOption("product", product),
+	)
+}
+
+func (s *productServer) DeleteProductHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	if err := s.productUC.DeleteProduct(ctx, r.PathValue("code")); err != nil {
+		responder.sendProductError(err, "deletion of product ended with error")
+		return
+	}
+
+	responder.sendResponse(http.StatusOK, "product successful deleted", nil)
+}
+
+// GetMovementsHandler журнал движения остатков товара, период задаётся параметрами from и to в RFC 3339.
+func (s *productServer) GetMovementsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	var filter models.MovementFilter
+	for param, bound := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		v := r.URL.Query().Get(param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			responder.sendResponse(http.StatusBadRequest, "can't get movements of product", models.ErrTimeRangeNotValid)
+			return
+		}
+		*bound = &t
+	}
+
+	movements, err := s.productUC.GetMovements(ctx, r.PathValue("code"), filter)
+	if err != nil {
+		responder.sendProductError(err, "getting movements of product ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"successful getting movements of product",
+		nil,
+		responseOption("movements", movements),
+	)
+}
+
+// sendProductError сопоставляет ошибки каталога товаров с кодами ответа.
+func (r *responder) sendProductError(err error, msg string) {
+	switch {
+	case errors.Is(err, models.ErrProductNotFound):
+		r.sendResponse(http.StatusNotFound, msg, models.ErrProductNotFound)
+	case errors.Is(err, models.ErrPageNotValid):
+		r.sendResponse(http.StatusBadRequest, msg, models.ErrPageNotValid)
+	case errors.Is(err, models.ErrTimeRangeNotValid):
+		r.sendResponse(http.StatusBadRequest, msg, models.ErrTimeRangeNotValid)
+	case errors.Is(err, models.ErrCodeNotValid):
+		r.sendResponse(http.StatusUnprocessableEntity, msg, models.ErrCodeNotValid)
+	case errors.Is(err, models.ErrProductNameNotValid):
+		r.sendResponse(http.StatusUnprocessableEntity, msg, models.ErrProductNameNotValid)
+	case errors.Is(err, models.ErrProductSizeNotValid):
+		r.sendResponse(http.StatusUnprocessableEntity, msg, models.ErrProductSizeNotValid)
+	case errors.Is(err, models.ErrEmptyProductPatch):
+		r.sendResponse(http.StatusUnprocessableEntity, msg, models.ErrEmptyProductPatch)
+	case errors.Is(err, models.ErrProductCodeExists):
+		r.sendResponse(http.StatusConflict, msg, models.ErrProductCodeExists)
+	case errors.Is(err, models.ErrProductHasReservations):
+		r.sendResponse(http.StatusConflict, msg, models.ErrProductHasReservations)
+	case errors.Is(err, models.ErrProductHasStock):
+		r.sendResponse(http.StatusConflict, msg, models.ErrProductHasStock)
+	case errors.Is(err, models.ErrProductHasHistory):
+		r.sendResponse(http.StatusConflict, msg, models.ErrProductHasHistory)
+	default:
+		logging.FromContext(r.ctx).Error().Err(err).Msg(msg)
+		r.sendResponse(http.StatusInternalServerError, msg, err)
+	}
+}
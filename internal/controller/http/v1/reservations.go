@@ -0,0 +1,140 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/controller/middleware"
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+)
+
+var ErrReservationIDNotValid = errors.New("reservation ID can only be an unsigned integer type")
+
+func (s *server) GetReservationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	reservationID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't get reservation", ErrReservationIDNotValid)
+		return
+	}
+
+	reservation, err := s.reservationUC.GetReservation(ctx, reservationID)
+	if err != nil {
+		responder.sendReservationError(err, "getting reservation ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"successful getting reservation",
+		nil,
+		responseOption("reservation", reservation),
+	)
+}
+
+func (s *server) ReleaseReservationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	reservationID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't release reservation", ErrReservationIDNotValid)
+		return
+	}
+
+	reservation, err := s.reservationUC.ReleaseReservation(ctx, reservationID, middleware.RequestOwner(r))
+	if err != nil {
+		responder.sendReservationError(err, "release of reservation ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"reservation successful released",
+		nil,
+		responseOption("reservation", reservation),
+	)
+}
+
+func (s *server) FulfilReservationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	reservationID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't fulfil reservation", ErrReservationIDNotValid)
+		return
+	}
+
+	reservation, err := s.reservationUC.FulfilReservation(ctx, reservationID, middleware.RequestOwner(r))
+	if err != nil {
+		responder.sendReservationError(err, "fulfilment of reservation ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"reservation successful fulfilled",
+		nil,
+		responseOption("reservation", reservation),
+	)
+}
+
+func (s *server) ExtendReservationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	reservationID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't extend reservation", ErrReservationIDNotValid)
+		return
+	}
+	ttl, err := time.ParseDuration(r.URL.Query().Get("ttl"))
+	if err != nil || ttl <= 0 {
+		responder.sendResponse(http.StatusBadRequest, "can't extend reservation", models.ErrTTLNotValid)
+		return
+	}
+
+	reservation, err := s.reservationUC.ExtendReservation(ctx, reservationID, middleware.RequestOwner(r), ttl)
+	if err != nil {
+		responder.sendReservationError(err, "extension of reservation ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"reservation successful extended",
+		nil,
+		responseOption("reservation", reservation),
+	)
+}
+
+// sendReservationError сопоставляет ошибки резерва с кодами ответа.
+func (r *responder) sendReservationError(err error, msg string) {
+	switch {
+	case errors.Is(err, models.ErrReservationNotFound):
+		r.sendResponse(http.StatusNotFound, msg, models.ErrReservationNotFound)
+	case errors.Is(err, models.ErrReservationOwner):
+		r.sendResponse(http.StatusForbidden, msg, models.ErrReservationOwner)
+	case errors.Is(err, models.ErrReservationNotActive):
+		r.sendResponse(http.StatusConflict, msg, models.ErrReservationNotActive)
+	case errors.Is(err, models.ErrStockNotOnHand):
+		r.sendResponse(http.StatusConflict, msg, models.ErrStockNotOnHand)
+	case errors.Is(err, models.ErrReservationInTransit):
+		r.sendResponse(http.StatusConflict, msg, models.ErrReservationInTransit)
+	case errors.Is(err, models.ErrTTLNotValid):
+		r.sendResponse(http.StatusBadRequest, msg, models.ErrTTLNotValid)
+	default:
+		logging.FromContext(r.ctx).Error().Err(err).Msg(msg)
+		r.sendResponse(http.StatusInternalServerError, msg, err)
+	}
+}
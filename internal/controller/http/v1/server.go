@@ -8,40 +8,46 @@ import (
 	"net/http"
 	"slices"
 	"strconv"
-	"sync"
+	"time"
 
+	"github.com/Shurubtsov/lamoda-test-task/internal/controller/middleware"
 	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
 	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/metrics"
 )
 
 var (
 	ErrNotValidatedProducts = errors.New("some of products was not validated")
 	ErrAllProductsNotValid  = errors.New("all products not validated")
+	ErrNotProcessedProducts = errors.New("some of products were not processed, see status of items")
 )
 
 type ReservationUsecase interface {
-	ProductReservation(ctx context.Context, products []models.Product) ([]models.Product, error)
+	ProductReservation(ctx context.Context, opts models.ReservationOptions, products []models.Item) (*models.Reservation, []models.Item, error)
+	GetReservation(ctx context.Context, reservationID uint64) (*models.Reservation, error)
+	ReleaseReservation(ctx context.Context, reservationID uint64, owner string) (*models.Reservation, error)
+	FulfilReservation(ctx context.Context, reservationID uint64, owner string) (*models.Reservation, error)
+	ExtendReservation(ctx context.Context, reservationID uint64, owner string, ttl time.Duration) (*models.Reservation, error)
 }
 
 type ExemptionUsecase interface {
-	ProductExemption(ctx context.Context, products []models.Product) ([]models.Product, error)
+	ProductExemption(ctx context.Context, opts models.ExemptionOptions, products []models.Item) ([]models.Item, error)
 }
 
 type ReceivingUsecase interface {
-	FindProducts(ctx context.Context, storageID uint) ([]models.Product, error)
+	FindStock(ctx context.Context, storageID uint) ([]models.Stock, error)
+	SetStock(ctx context.Context, storageID uint, stocks []models.Stock) ([]models.Stock, []string, error)
+	ExportStock(ctx context.Context, storageID uint, fn func(models.Stock) error) error
 }
 
 type server struct {
-	mu            sync.Mutex
-	productInUse  map[string]string
 	reservationUC ReservationUsecase
 	exemptionUC   ExemptionUsecase
 	receivingUC   ReceivingUsecase
 }
 
-func NewServer(ruc ReservationUsecase, euc ExemptionUsecase, reuc ReceivingUsecase, pie map[string]string) *server {
+func NewServer(ruc ReservationUsecase, euc ExemptionUsecase, reuc ReceivingUsecase) *server {
 	return &server{
-		productInUse:  pie,
 		reservationUC: ruc,
 		exemptionUC:   euc,
 		receivingUC:   reuc,
@@ -49,50 +55,82 @@ func NewServer(ruc ReservationUsecase, euc ExemptionUsecase, reuc ReceivingUseca
 }
 
 func (s *server) ReservationHandler(w http.ResponseWriter, r *http.Request) {
-	logger := logging.GetLogger()
-	ctx := context.Background()
-	responder := &responder{w: w}
+	w.Header().Add("Content-Type", "application/json")
+	logger := logging.FromContext(r.Context())
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
 
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
-	var products []models.Product
-	if err := json.NewDecoder(r.Body).Decode(&products); err != nil {
+	mode, err := models.ParseOperationMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't continue reservation of products on storage", err)
+		return
+	}
+	strategy, err := models.ParseAllocationStrategy(r.URL.Query().Get("strategy"))
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't continue reservation of products on storage", err)
+		return
+	}
+	opts := models.ReservationOptions{Owner: middleware.RequestOwner(r), Mode: mode, Strategy: strategy}
+	if ttl := r.URL.Query().Get("ttl"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			responder.sendResponse(http.StatusBadRequest, "can't continue reservation of products on storage", models.ErrTTLNotValid)
+			return
+		}
+		opts.TTL = d
+	}
+
+	var requested []models.Item
+	if err := json.NewDecoder(r.Body).Decode(&requested); err != nil {
 		msg := "unable to deserialize the request body"
 		responder.sendResponse(http.StatusUnprocessableEntity, msg, err)
 		return
 	}
 
-	notValidProducts := make([]models.Product, 0, len(products))
-	products = slices.DeleteFunc(products, func(p models.Product) bool {
-		if err := p.Validate(); err != nil {
-			logger.Warn().Err(err).Str("code", p.Code).Msg("one of product not validated")
-			notValidProducts = append(notValidProducts, p)
-			return true
-		}
-		return false
-	})
+	products, notValidProducts := validateProducts(ctx, requested)
 	if !(len(products) > 0) {
+		observeOutcomes("reservation", notValidProducts)
 		responder.sendResponse(
 			http.StatusUnprocessableEntity,
 			"can't continue reservation of products on storage",
 			ErrAllProductsNotValid,
+			responseOption("items", requested),
 			responseOption("not_valid", notValidProducts),
 		)
 		return
 	}
-	// очищаем кэш используемых системой товаров
-	defer func() {
-		for _, product := range products {
-			s.mu.Lock()
-			delete(s.productInUse, product.Code)
-			s.mu.Unlock()
-		}
-	}()
-	reservedProducts, err := s.reservationUC.ProductReservation(ctx, products)
+	if mode == models.ModeAtomic && len(notValidProducts) > 0 {
+		observeOutcomes("reservation", notValidProducts)
+		responder.sendResponse(
+			http.StatusUnprocessableEntity,
+			"can't continue atomic reservation of products on storage",
+			ErrNotValidatedProducts,
+			responseOption("items", requested),
+			responseOption("not_valid", notValidProducts),
+		)
+		return
+	}
+	reservation, processedProducts, err := s.reservationUC.ProductReservation(ctx, opts, products)
 	if err != nil {
+		if responder.sendInUseError(err) {
+			return
+		}
+		if errors.Is(err, models.ErrOperationRolledBack) {
+			items := collectItems(requested, processedProducts)
+			observeOutcomes("reservation", items)
+			responder.sendResponse(
+				http.StatusConflict,
+				"reservation was rolled back",
+				models.ErrOperationRolledBack,
+				responseOption("items", items),
+			)
+			return
+		}
 		logger.Error().Err(err).Msg("reservation product failed")
 		responder.sendResponse(
 			http.StatusInternalServerError,
@@ -102,11 +140,19 @@ func (s *server) ReservationHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(notValidProducts) > 0 {
+	items := collectItems(requested, processedProducts)
+	observeOutcomes("reservation", items)
+	reservedProducts := slices.DeleteFunc(slices.Clone(processedProducts), func(p models.Item) bool {
+		return !p.Succeeded()
+	})
+
+	if len(reservedProducts) != len(items) {
 		responder.sendResponse(
 			http.StatusMultiStatus,
 			"not at all products was reserved",
-			ErrNotValidatedProducts,
+			ErrNotProcessedProducts,
+			responseOption("reservation", reservation),
+			responseOption("items", items),
 			responseOption("reserved_products", reservedProducts),
 			responseOption("not_valid", notValidProducts),
 		)
@@ -117,55 +163,80 @@ func (s *server) ReservationHandler(w http.ResponseWriter, r *http.Request) {
 		http.StatusOK,
 		"reservation successful complete",
 		nil,
+		responseOption("reservation", reservation),
+		responseOption("items", items),
 		responseOption("reserved_products", reservedProducts),
 	)
 }
 
 func (s *server) ExemptionHandler(w http.ResponseWriter, r *http.Request) {
-	logger := logging.GetLogger()
-	ctx := context.Background()
-	responder := &responder{w: w}
+	w.Header().Add("Content-Type", "application/json")
+	logger := logging.FromContext(r.Context())
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
 
 	if r.Method != http.MethodDelete {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
-	var products []models.Product
-	if err := json.NewDecoder(r.Body).Decode(&products); err != nil {
+	mode, err := models.ParseOperationMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't continue exemption of products on storage", err)
+		return
+	}
+	opts := models.ExemptionOptions{Owner: middleware.RequestOwner(r), Mode: mode}
+
+	var requested []models.Item
+	if err := json.NewDecoder(r.Body).Decode(&requested); err != nil {
 		msg := "unable to deserialize the request body"
 		responder.sendResponse(http.StatusUnprocessableEntity, msg, err)
 		return
 	}
 
-	notValidProducts := make([]models.Product, 0, len(products))
-	products = slices.DeleteFunc(products, func(p models.Product) bool {
-		if err := p.Validate(); err != nil {
-			logger.Warn().Err(err).Str("code", p.Code).Msg("one of product not validated")
-			notValidProducts = append(notValidProducts, p)
-			return true
-		}
-		return false
-	})
+	products, notValidProducts := validateProducts(ctx, requested)
 	if !(len(products) > 0) {
+		observeOutcomes("exemption", notValidProducts)
 		responder.sendResponse(
 			http.StatusUnprocessableEntity,
 			"can't continue exemption of products on storage",
 			ErrAllProductsNotValid,
+			responseOption("items", requested),
 			responseOption("not_valid", notValidProducts),
 		)
 		return
 	}
-	// очищаем кэш используемых системой товаров
-	defer func() {
-		for _, product := range products {
-			s.mu.Lock()
-			delete(s.productInUse, product.Code)
-			s.mu.Unlock()
-		}
-	}()
-	exemptedProducts, err := s.exemptionUC.ProductExemption(ctx, products)
+	if mode == models.ModeAtomic && len(notValidProducts) > 0 {
+		observeOutcomes("exemption", notValidProducts)
+		responder.sendResponse(
+			http.StatusUnprocessableEntity,
+			"can't continue atomic exemption of products on storage",
+			ErrNotValidatedProducts,
+			responseOption("items", requested),
+			responseOption("not_valid", notValidProducts),
+		)
+		return
+	}
+	processedProducts, err := s.exemptionUC.ProductExemption(ctx, opts, products)
 	if err != nil {
+		if responder.sendInUseError(err) {
+			return
+		}
+		if errors.Is(err, models.ErrReservationInTransit) {
+			responder.sendResponse(http.StatusConflict, "exemption was ended with error", models.ErrReservationInTransit)
+			return
+		}
+		if errors.Is(err, models.ErrOperationRolledBack) {
+			items := collectItems(requested, processedProducts)
+			observeOutcomes("exemption", items)
+			responder.sendResponse(
+				http.StatusConflict,
+				"exemption was rolled back",
+				models.ErrOperationRolledBack,
+				responseOption("items", items),
+			)
+			return
+		}
 		logger.Error().Err(err).Msg("exemption product failed")
 		responder.sendResponse(
 			http.StatusInternalServerError,
@@ -175,11 +246,18 @@ func (s *server) ExemptionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(notValidProducts) > 0 {
+	items := collectItems(requested, processedProducts)
+	observeOutcomes("exemption", items)
+	exemptedProducts := slices.DeleteFunc(slices.Clone(processedProducts), func(p models.Item) bool {
+		return !p.Succeeded()
+	})
+
+	if len(exemptedProducts) != len(items) {
 		responder.sendResponse(
 			http.StatusMultiStatus,
 			"not at all products were exempt",
-			ErrNotValidatedProducts,
+			ErrNotProcessedProducts,
+			responseOption("items", items),
 			responseOption("exempted_products", exemptedProducts),
 			responseOption("not_valid", notValidProducts),
 		)
@@ -190,15 +268,16 @@ func (s *server) ExemptionHandler(w http.ResponseWriter, r *http.Request) {
 		http.StatusOK,
 		"exemption successful complete",
 		nil,
+		responseOption("items", items),
 		responseOption("exempted_products", exemptedProducts),
 	)
 }
 
 func (s *server) ReceivingProductsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "application/json")
-	logger := logging.GetLogger()
-	ctx := context.Background()
-	responder := &responder{w: w}
+	logger := logging.FromContext(r.Context())
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
 
 	if r.Method != http.MethodGet {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -224,8 +303,22 @@ func (s *server) ReceivingProductsHandler(w http.ResponseWriter, r *http.Request
 	}
 	*storage.ID = uint(storageID)
 
-	productsFromStorage, err := s.receivingUC.FindProducts(ctx, *storage.ID)
+	format, err := exportFormat(r)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't export stock of storage", err)
+		return
+	}
+	if format != "" {
+		s.exportStock(ctx, w, *storage.ID, format)
+		return
+	}
+
+	stocks, err := s.receivingUC.FindStock(ctx, *storage.ID)
 	if err != nil {
+		if errors.Is(err, models.ErrStorageNotFound) {
+			responder.sendResponse(http.StatusNotFound, "getting products from storage ended with error", models.ErrStorageNotFound)
+			return
+		}
 		logger.Error().Err(err).Msg("finding products failed")
 		responder.sendResponse(
 			http.StatusInternalServerError,
@@ -234,10 +327,12 @@ func (s *server) ReceivingProductsHandler(w http.ResponseWriter, r *http.Request
 		)
 		return
 	}
-	logger.Debug().Any("products", productsFromStorage).Msg("debug info")
-	var countAllProducts uint
-	for _, product := range productsFromStorage {
-		countAllProducts += product.Count
+	logger.Debug().Any("stocks", stocks).Msg("debug info")
+	var countAllProducts, countOnHand, countReserved uint
+	for _, stock := range stocks {
+		countAllProducts += stock.Available
+		countOnHand += stock.OnHand
+		countReserved += stock.Reserved
 	}
 
 	responder.sendResponse(
@@ -245,7 +340,9 @@ func (s *server) ReceivingProductsHandler(w http.ResponseWriter, r *http.Request
 		"successful getting all remaining products from storage",
 		nil,
 		responseOption("count_all_products", countAllProducts),
-		responseOption("remaining_products", productsFromStorage),
+		responseOption("count_on_hand", countOnHand),
+		responseOption("count_reserved", countReserved),
+		responseOption("remaining_products", stocks),
 	)
 }
 
@@ -269,9 +366,72 @@ func (r *responder) sendResponse(code int, msg string, err error, args ...respon
 	r.w.Write(data)
 }
 
+// validateProducts отмечает товары с невалидным кодом и возвращает товары для дальнейшей обработки.
+// Статус и причина из тела запроса не принимаются.
+func validateProducts(ctx context.Context, requested []models.Item) (valid, notValid []models.Item) {
+	logger := logging.FromContext(ctx)
+	valid = make([]models.Item, 0, len(requested))
+	notValid = make([]models.Item, 0, len(requested))
+	for i := range requested {
+		product := &requested[i]
+		product.SetOutcome("", "")
+		if err := product.Validate(); err != nil {
+			logger.Warn().Err(err).Str("code", product.Code).Msg("one of product not validated")
+			product.SetOutcome(models.ItemInvalidCode, models.ReasonCodeFormatMismatch)
+			notValid = append(notValid, *product)
+			continue
+		}
+		valid = append(valid, *product)
+	}
+	return valid, notValid
+}
+
+// collectItems собирает результат по каждому товару в порядке запроса.
+func collectItems(requested, processed []models.Item) []models.Item {
+	items := make([]models.Item, 0, len(requested))
+	j := 0
+	for _, product := range requested {
+		if product.Status == models.ItemInvalidCode || j >= len(processed) {
+			items = append(items, product)
+			continue
+		}
+		items = append(items, processed[j])
+		j++
+	}
+	return items
+}
+
+// observeOutcomes учитывает в метриках результат по каждому товару запроса.
+func observeOutcomes(operation string, items []models.Item) {
+	for _, item := range items {
+		if item.Status != "" {
+			metrics.ItemOutcome(operation, string(item.Status), item.Reason)
+		}
+	}
+}
+
+// sendInUseError отвечает конфликтом, если товары заблокированы другим запросом.
+func (r *responder) sendInUseError(err error) bool {
+	var inUse *models.ProductsInUseError
+	if !errors.As(err, &inUse) {
+		return false
+	}
+	// отказ временный: повтор, в том числе с тем же ключом идемпотентности, выполнит запрос заново
+	r.w.Header().Set(middleware.RetryAfterHeader, "1")
+	r.sendResponse(
+		http.StatusConflict,
+		"one or more products are already in use by another system",
+		models.ErrProductsInUse,
+		responseOption("in_use", inUse.Codes),
+	)
+	return true
+}
+
 // responder отправляет ответы клиенту
 type responder struct {
 	w http.ResponseWriter
+	// ctx контекст запроса, ошибки ответа пишутся в логгер запроса
+	ctx context.Context
 }
 type responseOpts struct {
 	text  string
@@ -0,0 +1,89 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+)
+
+var ErrStorageIDNotValid = errors.New("storage ID can only be an unsigned integer type")
+
+// SetStockHandler устанавливает количество товаров на складе: [{"code": "ID-SN", "on_hand": 10}].
+func (s *server) SetStockHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	logger := logging.FromContext(r.Context())
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	storageID, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't set stock of storage", ErrStorageIDNotValid)
+		return
+	}
+
+	var stocks []models.Stock
+	if err := json.NewDecoder(r.Body).Decode(&stocks); err != nil {
+		responder.sendResponse(http.StatusUnprocessableEntity, "unable to deserialize the request body", err)
+		return
+	}
+	if len(stocks) == 0 {
+		responder.sendResponse(http.StatusUnprocessableEntity, "can't set stock of storage", ErrAllProductsNotValid)
+		return
+	}
+	notValid := make([]models.Stock, 0)
+	for _, stock := range stocks {
+		if err := stock.Validate(); err != nil {
+			notValid = append(notValid, stock)
+		}
+	}
+	if len(notValid) > 0 {
+		responder.sendResponse(
+			http.StatusUnprocessableEntity,
+			"can't set stock of storage",
+			ErrNotValidatedProducts,
+			responseOption("not_valid", notValid),
+		)
+		return
+	}
+
+	updated, notFound, err := s.receivingUC.SetStock(ctx, uint(storageID), stocks)
+	if err != nil {
+		var belowReserved *models.StockBelowReservedError
+		switch {
+		case errors.Is(err, models.ErrProductsNotFound):
+			responder.sendResponse(
+				http.StatusUnprocessableEntity,
+				"can't set stock of storage",
+				models.ErrProductsNotFound,
+				responseOption("not_found", notFound),
+			)
+		case errors.Is(err, models.ErrStorageNotFound):
+			responder.sendResponse(http.StatusNotFound, "can't set stock of storage", models.ErrStorageNotFound)
+		case errors.As(err, &belowReserved):
+			responder.sendResponse(
+				http.StatusConflict,
+				"can't set stock of storage",
+				models.ErrStockBelowReserved,
+				responseOption("conflicts", belowReserved.Stocks),
+			)
+		default:
+			if responder.sendInUseError(err) {
+				return
+			}
+			logger.Error().Err(err).Msg("setting stock failed")
+			responder.sendResponse(http.StatusInternalServerError, "setting stock ended with error", err)
+		}
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"stock of storage successful updated",
+		nil,
+		responseOption("stocks", updated),
+	)
+}
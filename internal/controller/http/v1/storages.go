@@ -0,0 +1,171 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+)
+
+type StorageUsecase interface {
+	CreateStorage(ctx context.Context, patch models.StoragePatch) (*models.Storage, error)
+	GetStorages(ctx context.Context) ([]models.Storage, error)
+	GetStorage(ctx context.Context, storageID uint) (*models.Storage, error)
+	UpdateStorage(ctx context.Context, storageID uint, patch models.StoragePatch) (*models.Storage, error)
+	DeleteStorage(ctx context.Context, storageID uint) error
+}
+
+// storageServer обработчики управления складами.
+type storageServer struct {
+	storageUC StorageUsecase
+}
+
+func NewStorageServer(suc StorageUsecase) *storageServer {
+	return &storageServer{storageUC: suc}
+}
+
+func (s *storageServer) CreateStorageHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	var patch models.StoragePatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		responder.sendResponse(http.StatusUnprocessableEntity, "unable to deserialize the request body", err)
+		return
+	}
+
+	storage, err := s.storageUC.CreateStorage(ctx, patch)
+	if err != nil {
+		responder.sendStorageError(err, "creation of storage ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusCreated,
+		"storage successful created",
+		nil,
+		responseOption("storage", storage),
+	)
+}
+
+func (s *storageServer) GetStoragesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	storages, err := s.storageUC.GetStorages(ctx)
+	if err != nil {
+		responder.sendStorageError(err, "getting storages ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"successful getting storages",
+		nil,
+		responseOption("storages", storages),
+	)
+}
+
+func (s *storageServer) GetStorageHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	storageID, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't get storage", ErrStorageIDNotValid)
+		return
+	}
+
+	storage, err := s.storageUC.GetStorage(ctx, uint(storageID))
+	if err != nil {
+		responder.sendStorageError(err, "getting storage ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"successful getting storage",
+		nil,
+		responseOption("storage", storage),
+	)
+}
+
+func (s *storageServer) UpdateStorageHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	storageID, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't update storage", ErrStorageIDNotValid)
+		return
+	}
+
+	var patch models.StoragePatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		responder.sendResponse(http.StatusUnprocessableEntity, "unable to deserialize the request body", err)
+		return
+	}
+
+	storage, err := s.storageUC.UpdateStorage(ctx, uint(storageID), patch)
+	if err != nil {
+		responder.sendStorageError(err, "update of storage ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"storage successful updated",
+		nil,
+		responseOption("storage", storage),
+	)
+}
+
+func (s *storageServer) DeleteStorageHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	storageID, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't delete storage", ErrStorageIDNotValid)
+		return
+	}
+
+	if err := s.storageUC.DeleteStorage(ctx, uint(storageID)); err != nil {
+		responder.sendStorageError(err, "deletion of storage ended with error")
+		return
+	}
+
+	responder.sendResponse(http.StatusOK, "storage successful deleted", nil)
+}
+
+// sendStorageError сопоставляет ошибки склада с кодами ответа.
+func (r *responder) sendStorageError(err error, msg string) {
+	switch {
+	case errors.Is(err, models.ErrStorageNotFound):
+		r.sendResponse(http.StatusNotFound, msg, models.ErrStorageNotFound)
+	case errors.Is(err, models.ErrStorageNameNotValid):
+		r.sendResponse(http.StatusUnprocessableEntity, msg, models.ErrStorageNameNotValid)
+	case errors.Is(err, models.ErrEmptyStoragePatch):
+		r.sendResponse(http.StatusUnprocessableEntity, msg, models.ErrEmptyStoragePatch)
+	case errors.Is(err, models.ErrStorageNameExists):
+		r.sendResponse(http.StatusConflict, msg, models.ErrStorageNameExists)
+	case errors.Is(err, models.ErrStorageHasReservations):
+		r.sendResponse(http.StatusConflict, msg, models.ErrStorageHasReservations)
+	case errors.Is(err, models.ErrStorageHasStock):
+		r.sendResponse(http.StatusConflict, msg, models.ErrStorageHasStock)
+	case errors.Is(err, models.ErrStorageHasHistory):
+		r.sendResponse(http.StatusConflict, msg, models.ErrStorageHasHistory)
+	default:
+		logging.FromContext(r.ctx).Error().Err(err).Msg(msg)
+		r.sendResponse(http.StatusInternalServerError, msg, err)
+	}
+}
@@ -0,0 +1,159 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+)
+
+var ErrTransferIDNotValid = errors.New("transfer ID can only be an unsigned integer type")
+
+type TransferUsecase interface {
+	CreateTransfer(ctx context.Context, request models.Transfer) (*models.Transfer, []string, error)
+	GetTransfer(ctx context.Context, transferID uint64) (*models.Transfer, error)
+	CompleteTransfer(ctx context.Context, transferID uint64) (*models.Transfer, error)
+	CancelTransfer(ctx context.Context, transferID uint64) (*models.Transfer, error)
+}
+
+// transferServer обработчики перемещений между складами.
+type transferServer struct {
+	transferUC TransferUsecase
+}
+
+func NewTransferServer(tuc TransferUsecase) *transferServer {
+	return &transferServer{transferUC: tuc}
+}
+
+// CreateTransferHandler создаёт перемещение:
+// {"source_storage_id": 2, "destination_storage_id": 1, "reservations": "block", "lines": [{"code": "LK-7", "quantity": 5}]}.
+func (s *transferServer) CreateTransferHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	var request models.Transfer
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responder.sendResponse(http.StatusUnprocessableEntity, "unable to deserialize the request body", err)
+		return
+	}
+
+	created, notFound, err := s.transferUC.CreateTransfer(ctx, request)
+	if err != nil {
+		responder.sendTransferError(err, notFound, "creation of transfer ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusCreated,
+		"transfer successful created",
+		nil,
+		responseOption("transfer", created),
+	)
+}
+
+func (s *transferServer) GetTransferHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	transferID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't get transfer", ErrTransferIDNotValid)
+		return
+	}
+
+	transfer, err := s.transferUC.GetTransfer(ctx, transferID)
+	if err != nil {
+		responder.sendTransferError(err, nil, "getting transfer ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"successful getting transfer",
+		nil,
+		responseOption("transfer", transfer),
+	)
+}
+
+func (s *transferServer) CompleteTransferHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	transferID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't complete transfer", ErrTransferIDNotValid)
+		return
+	}
+
+	transfer, err := s.transferUC.CompleteTransfer(ctx, transferID)
+	if err != nil {
+		responder.sendTransferError(err, nil, "completion of transfer ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"transfer successful completed",
+		nil,
+		responseOption("transfer", transfer),
+	)
+}
+
+func (s *transferServer) CancelTransferHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	transferID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't cancel transfer", ErrTransferIDNotValid)
+		return
+	}
+
+	transfer, err := s.transferUC.CancelTransfer(ctx, transferID)
+	if err != nil {
+		responder.sendTransferError(err, nil, "cancellation of transfer ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"transfer successful cancelled",
+		nil,
+		responseOption("transfer", transfer),
+	)
+}
+
+// sendTransferError сопоставляет ошибки перемещения с кодами ответа.
+func (r *responder) sendTransferError(err error, notFound []string, msg string) {
+	var insufficient *models.InsufficientStockError
+	switch {
+	case errors.Is(err, models.ErrTransferNotFound):
+		r.sendResponse(http.StatusNotFound, msg, models.ErrTransferNotFound)
+	case errors.Is(err, models.ErrStorageNotFound):
+		r.sendResponse(http.StatusNotFound, msg, models.ErrStorageNotFound)
+	case errors.Is(err, models.ErrTransferNotInTransit):
+		r.sendResponse(http.StatusConflict, msg, models.ErrTransferNotInTransit)
+	case errors.As(err, &insufficient):
+		r.sendResponse(http.StatusConflict, msg, models.ErrInsufficientStock, responseOption("insufficient", insufficient.Lines))
+	case errors.Is(err, models.ErrProductsNotFound):
+		r.sendResponse(http.StatusUnprocessableEntity, msg, models.ErrProductsNotFound, responseOption("not_found", notFound))
+	case errors.Is(err, models.ErrNilStorageID), errors.Is(err, models.ErrTransferSameStorage),
+		errors.Is(err, models.ErrTransferLinesEmpty), errors.Is(err, models.ErrCodeNotValid),
+		errors.Is(err, models.ErrTransferQuantityNotValid), errors.Is(err, models.ErrReservationPolicyNotValid):
+		r.sendResponse(http.StatusUnprocessableEntity, msg, err)
+	default:
+		if r.sendInUseError(err) {
+			return
+		}
+		logging.FromContext(r.ctx).Error().Err(err).Msg(msg)
+		r.sendResponse(http.StatusInternalServerError, msg, err)
+	}
+}
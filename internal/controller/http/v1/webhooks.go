@@ -0,0 +1,249 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+)
+
+var (
+	ErrWebhookIDNotValid  = errors.New("webhook ID can only be an unsigned integer type")
+	ErrDeliveryIDNotValid = errors.New("delivery ID can only be an unsigned integer type")
+)
+
+type WebhookUsecase interface {
+	CreateWebhook(ctx context.Context, request models.Webhook) (*models.Webhook, error)
+	GetWebhooks(ctx context.Context) ([]models.Webhook, error)
+	GetWebhook(ctx context.Context, webhookID uint) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID uint) error
+	GetDeliveries(ctx context.Context, filter models.DeliveryFilter) ([]models.WebhookDelivery, error)
+	RetryDelivery(ctx context.Context, deliveryID uint64) (*models.WebhookDelivery, error)
+}
+
+// webhookServer обработчики подписок на события и журнала их доставок.
+type webhookServer struct {
+	webhookUC WebhookUsecase
+}
+
+func NewWebhookServer(wuc WebhookUsecase) *webhookServer {
+	return &webhookServer{webhookUC: wuc}
+}
+
+// CreateWebhookHandler подписывает URL на события:
+// {"url": "http://...", "events": ["reservation.created"], "secret": "..."}.
+func (s *webhookServer) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	var request models.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		responder.sendResponse(http.StatusUnprocessableEntity, "unable to deserialize the request body", err)
+		return
+	}
+
+	created, err := s.webhookUC.CreateWebhook(ctx, request)
+	if err != nil {
+		responder.sendWebhookError(err, "creation of webhook ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusCreated,
+		"webhook successful created",
+		nil,
+		responseOption("webhook", created),
+	)
+}
+
+func (s *webhookServer) GetWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	webhooks, err := s.webhookUC.GetWebhooks(ctx)
+	if err != nil {
+		responder.sendWebhookError(err, "getting webhooks ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"successful getting webhooks",
+		nil,
+		responseOption("webhooks", webhooks),
+	)
+}
+
+func (s *webhookServer) GetWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	webhookID, err := strconv.ParseUint(r.PathValue("id"), 10, 0)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't get webhook", ErrWebhookIDNotValid)
+		return
+	}
+
+	found, err := s.webhookUC.GetWebhook(ctx, uint(webhookID))
+	if err != nil {
+		responder.sendWebhookError(err, "getting webhook ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"successful getting webhook",
+		nil,
+		responseOption("webhook", found),
+	)
+}
+
+func (s *webhookServer) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	webhookID, err := strconv.ParseUint(r.PathValue("id"), 10, 0)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't delete webhook", ErrWebhookIDNotValid)
+		return
+	}
+
+	if err := s.webhookUC.DeleteWebhook(ctx, uint(webhookID)); err != nil {
+		responder.sendWebhookError(err, "deletion of webhook ended with error")
+		return
+	}
+
+	responder.sendResponse(http.StatusOK, "webhook successful deleted", nil)
+}
+
+// GetDeliveriesHandler журнал доставок подписки, отбор по параметру status.
+func (s *webhookServer) GetDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	webhookID, err := strconv.ParseUint(r.PathValue("id"), 10, 0)
+	if err != nil || webhookID == 0 {
+		responder.sendResponse(http.StatusBadRequest, "can't get deliveries of webhook", ErrWebhookIDNotValid)
+		return
+	}
+	filter := models.DeliveryFilter{
+		WebhookID: uint(webhookID),
+		Status:    models.DeliveryStatus(r.URL.Query().Get("status")),
+	}
+	if err := parsePage(r, &filter.Limit, &filter.Offset); err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't get deliveries of webhook", err)
+		return
+	}
+
+	deliveries, err := s.webhookUC.GetDeliveries(ctx, filter)
+	if err != nil {
+		responder.sendWebhookError(err, "getting deliveries of webhook ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"successful getting deliveries of webhook",
+		nil,
+		responseOption("deliveries", deliveries),
+	)
+}
+
+// GetDeadLettersHandler доставки всех подписок, попытки которых исчерпаны.
+func (s *webhookServer) GetDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	filter := models.DeliveryFilter{Status: models.DeliveryDead}
+	if err := parsePage(r, &filter.Limit, &filter.Offset); err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't get dead letters", err)
+		return
+	}
+
+	deliveries, err := s.webhookUC.GetDeliveries(ctx, filter)
+	if err != nil {
+		responder.sendWebhookError(err, "getting dead letters ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"successful getting dead letters",
+		nil,
+		responseOption("deliveries", deliveries),
+	)
+}
+
+// RetryDeliveryHandler возвращает недоставленное событие в очередь доставки.
+func (s *webhookServer) RetryDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	ctx := r.Context()
+	responder := &responder{w: w, ctx: ctx}
+
+	deliveryID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		responder.sendResponse(http.StatusBadRequest, "can't retry delivery", ErrDeliveryIDNotValid)
+		return
+	}
+
+	delivery, err := s.webhookUC.RetryDelivery(ctx, deliveryID)
+	if err != nil {
+		responder.sendWebhookError(err, "retry of delivery ended with error")
+		return
+	}
+
+	responder.sendResponse(
+		http.StatusOK,
+		"delivery successful queued",
+		nil,
+		responseOption("delivery", delivery),
+	)
+}
+
+// parsePage читает параметры limit и offset, отсутствующие параметры остаются нулевыми.
+func parsePage(r *http.Request, limit, offset *int) error {
+	var err error
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if *limit, err = strconv.Atoi(v); err != nil {
+			return models.ErrPageNotValid
+		}
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		if *offset, err = strconv.Atoi(v); err != nil {
+			return models.ErrPageNotValid
+		}
+	}
+	return nil
+}
+
+// sendWebhookError сопоставляет ошибки подписок и доставок с кодами ответа.
+func (r *responder) sendWebhookError(err error, msg string) {
+	switch {
+	case errors.Is(err, models.ErrWebhookNotFound):
+		r.sendResponse(http.StatusNotFound, msg, models.ErrWebhookNotFound)
+	case errors.Is(err, models.ErrDeliveryNotFound):
+		r.sendResponse(http.StatusNotFound, msg, models.ErrDeliveryNotFound)
+	case errors.Is(err, models.ErrDeliveryNotDead):
+		r.sendResponse(http.StatusConflict, msg, models.ErrDeliveryNotDead)
+	case errors.Is(err, models.ErrPageNotValid):
+		r.sendResponse(http.StatusBadRequest, msg, models.ErrPageNotValid)
+	case errors.Is(err, models.ErrDeliveryStatusNotValid):
+		r.sendResponse(http.StatusBadRequest, msg, models.ErrDeliveryStatusNotValid)
+	case errors.Is(err, models.ErrWebhookURLNotValid), errors.Is(err, models.ErrWebhookEventsNotValid),
+		errors.Is(err, models.ErrWebhookSecretRequired):
+		r.sendResponse(http.StatusUnprocessableEntity, msg, err)
+	default:
+		logging.FromContext(r.ctx).Error().Err(err).Msg(msg)
+		r.sendResponse(http.StatusInternalServerError, msg, err)
+	}
+}
@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	RetryAfterHeader          = "Retry-After"
+	maxIdempotencyKeyLength   = 255
+	idempotencyCleanupTimeout = time.Second * 5
+)
+
+type IdempotencyRepo interface {
+	AcquireIdempotencyKey(ctx context.Context, key, requestHash string, retention, lease time.Duration) (string, *models.IdempotentResponse, error)
+	SaveIdempotentResponse(ctx context.Context, response models.IdempotentResponse) error
+	DeleteIdempotencyKey(ctx context.Context, key, leaseToken string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+type idempotency struct {
+	repository IdempotencyRepo
+	retention  time.Duration
+	lease      time.Duration
+}
+
+// NewIdempotency создаёт обработку ключей идемпотентности: ответы хранятся retention,
+// ключ незавершённого запроса можно перезанять повтором через lease.
+func NewIdempotency(r IdempotencyRepo, retention, lease time.Duration) *idempotency {
+	return &idempotency{
+		repository: r,
+		retention:  retention,
+		lease:      lease,
+	}
+}
+
+// Idempotent сохраняет первый ответ на запрос с заголовком Idempotency-Key
+// и воспроизводит его без изменений на повторные запросы с тем же ключом.
+func (i *idempotency) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		logger := logging.FromContext(r.Context())
+		if len(key) > maxIdempotencyKeyLength {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(models.ErrIdempotencyKeyNotValid.Error()))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewBuffer(body))
+		requestHash := hashRequest(r, body)
+
+		leaseToken, stored, err := i.repository.AcquireIdempotencyKey(r.Context(), key, requestHash, i.retention, i.lease)
+		if err != nil {
+			logger.Error().Err(err).Str("key", key).Msg("can't acquire idempotency key")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal server error"))
+			return
+		}
+
+		if leaseToken == "" {
+			switch {
+			case stored.RequestHash != requestHash:
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(models.ErrIdempotencyKeyMismatch.Error()))
+			case !stored.Completed:
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(models.ErrIdempotencyKeyInProgress.Error()))
+			default:
+				logger.Info().Str("key", key).Msg("replay stored response")
+				for name, values := range stored.Headers {
+					w.Header()[name] = values
+				}
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(stored.StatusCode)
+				w.Write(stored.Body)
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r)
+
+		// ошибки сервера и временные отказы (Retry-After, например товары заблокированы другим запросом)
+		// не сохраняются, чтобы клиент мог повторить запрос с тем же ключом
+		if recorder.status >= http.StatusInternalServerError || w.Header().Get(RetryAfterHeader) != "" {
+			if err := i.repository.DeleteIdempotencyKey(context.WithoutCancel(r.Context()), key, leaseToken); err != nil {
+				logger.Error().Err(err).Str("key", key).Msg("can't delete idempotency key")
+			}
+			return
+		}
+
+		response := models.IdempotentResponse{
+			Key:         key,
+			RequestHash: requestHash,
+			LeaseToken:  leaseToken,
+			StatusCode:  recorder.status,
+			Headers:     storedHeaders(w.Header()),
+			Body:        recorder.body.Bytes(),
+		}
+		if err := i.repository.SaveIdempotentResponse(context.WithoutCancel(r.Context()), response); err != nil {
+			logger.Error().Err(err).Str("key", key).Msg("can't save idempotent response")
+		}
+	}
+}
+
+// Run периодически удаляет ключи идемпотентности, срок хранения которых истёк.
+func (i *idempotency) Run(ctx context.Context, interval time.Duration) {
+	logger := logging.FromContext(ctx)
+	if interval <= 0 {
+		logger.Warn().Dur("interval", interval).Msg("idempotency keys cleanup disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cleanupCtx, cancel := context.WithTimeout(ctx, idempotencyCleanupTimeout)
+			deleted, err := i.repository.DeleteExpiredIdempotencyKeys(cleanupCtx, i.retention)
+			cancel()
+			if err != nil {
+				logger.Error().Err(err).Msg("cleanup of idempotency keys failed")
+				continue
+			}
+			if deleted > 0 {
+				logger.Info().Int64("deleted", deleted).Msg("expired idempotency keys deleted")
+			}
+		}
+	}
+}
+
+// hashRequest считает отпечаток запроса, по которому определяется повтор с другим телом.
+func hashRequest(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.RequestURI()))
+	h.Write([]byte{0})
+	h.Write([]byte(RequestOwner(r)))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// storedHeaders заголовки ответа для воспроизведения, кроме относящихся к конкретному запросу.
+func storedHeaders(header http.Header) http.Header {
+	stored := header.Clone()
+	for _, name := range []string{RequestIDHeader, IdempotentReplayedHeader, "Content-Length", "Date"} {
+		stored.Del(name)
+	}
+	return stored
+}
+
+// responseRecorder копирует ответ обработчика для сохранения.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(code int) {
+	if !rr.wroteHeader {
+		rr.status = code
+		rr.wroteHeader = true
+	}
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.wroteHeader = true
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/pkg/metrics"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Instrument учитывает запросы маршрута pattern в метриках и начинает для каждого запроса спан,
+// продолжающий трассу вызывающей стороны из заголовка traceparent. Меткой route служит путь шаблона
+// маршрута без метода, поэтому количество значений метки не зависит от параметров пути.
+func Instrument(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	route := pattern
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		route = path
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		done := metrics.RequestStarted(route)
+		defer done()
+
+		ctx, span := tracing.StartServer(r.Context(), propagation.HeaderCarrier(r.Header), r.Method+" "+route,
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.HTTPRoute(route),
+			semconv.URLPath(r.URL.Path),
+		)
+		defer span.End()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			// прерванный обработчик (http.ErrAbortHandler) учитывается как ошибка сервера
+			p := recover()
+			status := sw.status
+			if p != nil {
+				status = http.StatusInternalServerError
+			}
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+			metrics.ObserveRequest(r.Method, route, status, time.Since(start))
+			if p != nil {
+				panic(p)
+			}
+		}()
+		next(sw, r.WithContext(ctx))
+	}
+}
+
+// statusWriter запоминает код ответа обработчика и размер тела ответа.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	written     int
+	wroteHeader bool
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if !sw.wroteHeader {
+		sw.status = code
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	sw.wroteHeader = true
+	n, err := sw.ResponseWriter.Write(b)
+	sw.written += n
+	return n, err
+}
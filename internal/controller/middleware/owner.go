@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+)
+
+// OwnerHeader заголовок, которым система-клиент представляется при работе с резервами.
+const OwnerHeader = "X-Owner"
+
+// RequestOwner определяет владельца резерва по заголовку X-Owner,
+// если заголовок не передан, владельцем считается IP адрес клиента.
+func RequestOwner(r *http.Request) string {
+	if owner := r.Header.Get(OwnerHeader); owner != "" {
+		return owner
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
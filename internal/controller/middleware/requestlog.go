@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+// RequestLog присваивает запросу идентификатор из заголовка X-Request-ID (или новый, если заголовка нет)
+// и возвращает его в ответе, сохраняет в контексте запроса логгер с полями request_id, client, method
+// и route и после обработки пишет запись журнала доступа с кодом ответа и временем обработки.
+func RequestLog(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	route := pattern
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		route = path
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("request.id", requestID))
+
+		ctx := logging.WithFields(r.Context(), map[string]any{
+			"request_id": requestID,
+			"client":     RequestOwner(r),
+			"method":     r.Method,
+			"route":      route,
+		})
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			// запись о прерванном обработчике (http.ErrAbortHandler) пишется до повторной паники
+			p := recover()
+			status := sw.status
+			if p != nil {
+				status = http.StatusInternalServerError
+			}
+			level := zerolog.InfoLevel
+			if status >= http.StatusInternalServerError {
+				level = zerolog.ErrorLevel
+			}
+			logging.FromContext(ctx).WithLevel(level).
+				Str("path", r.URL.RequestURI()).
+				Int("status", status).
+				Bool("aborted", p != nil).
+				Int("bytes", sw.written).
+				Dur("latency", time.Since(start)).
+				Msg("request handled")
+			if p != nil {
+				panic(p)
+			}
+		}()
+		next(sw, r.WithContext(ctx))
+	}
+}
+
+// validRequestID принимает идентификатор вызывающей стороны, если он не длиннее 128 символов
+// и состоит из печатных ASCII символов.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrThresholdNotValid   = errors.New("threshold must be greater than zero")
+	ErrThresholdNotFound   = errors.New("threshold not found")
+	ErrAlertStatusNotValid = errors.New("alert status can only be open or resolved")
+)
+
+// AlertStatus состояние предупреждения о низком остатке.
+type AlertStatus string
+
+const (
+	AlertOpen     AlertStatus = "open"
+	AlertResolved AlertStatus = "resolved"
+)
+
+func (s AlertStatus) Validate() error {
+	switch s {
+	case "", AlertOpen, AlertResolved:
+		return nil
+	}
+	return ErrAlertStatusNotValid
+}
+
+// Threshold порог пополнения товара. Без склада порог действует на всех складах, где есть остаток товара,
+// порог склада его переопределяет.
+type Threshold struct {
+	ProductID uint      `json:"product_id"`
+	Code      string    `json:"code"`
+	StorageID *uint     `json:"storage_id"`
+	Threshold uint      `json:"threshold"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (t Threshold) Validate() error {
+	if t.Threshold == 0 {
+		return ErrThresholdNotValid
+	}
+	return nil
+}
+
+// StockAlert предупреждение о том, что свободный остаток товара на складе опустился ниже порога.
+// Threshold и Available фиксируются на момент появления предупреждения.
+type StockAlert struct {
+	ID         uint64      `json:"id"`
+	StorageID  uint        `json:"storage_id"`
+	ProductID  uint        `json:"product_id"`
+	Code       string      `json:"code"`
+	Threshold  uint        `json:"threshold"`
+	Available  uint        `json:"available"`
+	Status     AlertStatus `json:"status"`
+	CreatedAt  time.Time   `json:"created_at"`
+	ResolvedAt *time.Time  `json:"resolved_at,omitempty"`
+}
+
+// AlertFilter параметры выборки предупреждений, нулевые значения Status, StorageID и Code
+// не ограничивают выборку.
+type AlertFilter struct {
+	Status    AlertStatus
+	StorageID uint
+	Code      string
+	Limit     int
+	Offset    int
+}
@@ -0,0 +1,32 @@
+package models
+
+import "errors"
+
+var ErrStrategyNotValid = errors.New("strategy must be one of: single, priority, split")
+
+// AllocationStrategy способ распределения запрошенных товаров по складам.
+type AllocationStrategy string
+
+const (
+	// AllocationSingle резервирует все товары на одном складе, который может их покрыть.
+	AllocationSingle AllocationStrategy = "single"
+	// AllocationPriority резервирует каждый товар целиком на первом по приоритету складе, где его достаточно.
+	AllocationPriority AllocationStrategy = "priority"
+	// AllocationSplit делит товар между складами в порядке приоритета.
+	AllocationSplit AllocationStrategy = "split"
+)
+
+// ParseAllocationStrategy разбирает стратегию из запроса, пустое значение означает стратегию по умолчанию.
+func ParseAllocationStrategy(strategy string) (AllocationStrategy, error) {
+	switch s := AllocationStrategy(strategy); s {
+	case "", AllocationSingle, AllocationPriority, AllocationSplit:
+		return s, nil
+	}
+	return "", ErrStrategyNotValid
+}
+
+// Allocation количество единиц товара, выделенное на складе.
+type Allocation struct {
+	StorageID uint `json:"storage_id"`
+	Quantity  uint `json:"quantity"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCountNotFound        = errors.New("inventory count not found")
+	ErrCountNotOpen         = errors.New("inventory count is not open")
+	ErrCountLinesEmpty      = errors.New("inventory count must contain at least one line")
+	ErrCountedRequired      = errors.New("counted quantity is required for every line")
+	ErrCountedBelowReserved = errors.New("counted quantity is lower than reserved quantity")
+)
+
+// CountStatus состояние пересчёта.
+type CountStatus string
+
+const (
+	CountOpen      CountStatus = "open"
+	CountCommitted CountStatus = "committed"
+	CountCancelled CountStatus = "cancelled"
+)
+
+// InventoryCount пересчёт остатков склада. Пересчитанными считаются только товары из строк пересчёта,
+// остатки остальных товаров склада не изменяются.
+type InventoryCount struct {
+	ID          uint64      `json:"id"`
+	StorageID   uint        `json:"storage_id"`
+	Status      CountStatus `json:"status"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	CommittedAt *time.Time  `json:"committed_at,omitempty"`
+	Lines       []CountLine `json:"lines"`
+}
+
+// CountLine строка пересчёта: Counted - фактическое количество, OnHand и Reserved - остаток и резерв
+// по данным системы (для применённого пересчёта - на момент применения), Variance - расхождение.
+// Conflict отмечает строки, у которых фактическое количество меньше зарезервированного.
+type CountLine struct {
+	ProductID uint   `json:"product_id,omitempty"`
+	Code      string `json:"code"`
+	Name      string `json:"name,omitempty"`
+	Counted   *uint  `json:"counted"`
+	OnHand    uint   `json:"on_hand"`
+	Reserved  uint   `json:"reserved"`
+	Variance  int    `json:"variance"`
+	Conflict  bool   `json:"conflict,omitempty"`
+}
+
+// SetSystem заполняет данные системы и расхождение строки.
+func (l *CountLine) SetSystem(onHand, reserved uint) {
+	l.OnHand = onHand
+	l.Reserved = reserved
+	l.Variance = int(*l.Counted) - int(onHand)
+	l.Conflict = *l.Counted < reserved
+}
+
+func ValidateCountLines(lines []CountLine) error {
+	if len(lines) == 0 {
+		return ErrCountLinesEmpty
+	}
+	for _, line := range lines {
+		if err := (Product{Code: line.Code}).Validate(); err != nil {
+			return err
+		}
+		if line.Counted == nil {
+			return ErrCountedRequired
+		}
+	}
+	return nil
+}
+
+// CountConflictError строки пересчёта, применение которых сделало бы остаток меньше активных резервов.
+type CountConflictError struct {
+	Lines []CountLine
+}
+
+func (e *CountConflictError) Error() string {
+	codes := make([]string, 0, len(e.Lines))
+	for _, line := range e.Lines {
+		codes = append(codes, line.Code)
+	}
+	return ErrCountedBelowReserved.Error() + ": " + strings.Join(codes, ", ")
+}
+
+func (e *CountConflictError) Unwrap() error {
+	return ErrCountedBelowReserved
+}
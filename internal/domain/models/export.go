@@ -0,0 +1,23 @@
+package models
+
+import "errors"
+
+var ErrExportFormatNotValid = errors.New("export format must be csv, ndjson or xlsx")
+
+// ExportFormat формат выгрузки остатков склада.
+type ExportFormat string
+
+const (
+	ExportCSV    ExportFormat = "csv"
+	ExportNDJSON ExportFormat = "ndjson"
+	ExportXLSX   ExportFormat = "xlsx"
+)
+
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch f := ExportFormat(s); f {
+	case ExportCSV, ExportNDJSON, ExportXLSX:
+		return f, nil
+	default:
+		return "", ErrExportFormatNotValid
+	}
+}
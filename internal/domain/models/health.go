@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrShuttingDown             = errors.New("service is shutting down")
+	ErrMigrationsNotApplied     = errors.New("migrations were not applied")
+	ErrMigrationDirty           = errors.New("last migration failed, database is dirty")
+	ErrMigrationVersionMismatch = errors.New("migration version does not match expected")
+)
+
+// CheckOK результат успешной проверки готовности.
+const CheckOK = "ok"
+
+// Readiness готовность сервиса принимать запросы, в Checks результат каждой проверки: ok или текст ошибки.
+type Readiness struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// MigrationState версия схемы бд, применённая golang-migrate.
+type MigrationState struct {
+	Version  uint `json:"version"`
+	Dirty    bool `json:"dirty"`
+	Expected uint `json:"expected"`
+}
+
+// PoolStats состояние пула соединений с бд.
+type PoolStats struct {
+	MaxConns                int32         `json:"max_conns"`
+	TotalConns              int32         `json:"total_conns"`
+	AcquiredConns           int32         `json:"acquired_conns"`
+	IdleConns               int32         `json:"idle_conns"`
+	ConstructingConns       int32         `json:"constructing_conns"`
+	AcquireCount            int64         `json:"acquire_count"`
+	AcquireDuration         time.Duration `json:"acquire_duration_ns"`
+	EmptyAcquireCount       int64         `json:"empty_acquire_count"`
+	CanceledAcquireCount    int64         `json:"canceled_acquire_count"`
+	NewConnsCount           int64         `json:"new_conns_count"`
+	MaxLifetimeDestroyCount int64         `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64         `json:"max_idle_destroy_count"`
+}
+
+// BuildInfo сведения о сборке из бинарного файла.
+type BuildInfo struct {
+	GoVersion string `json:"go_version"`
+	Module    string `json:"module"`
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// ServiceStatus подробное состояние сервиса.
+type ServiceStatus struct {
+	Readiness
+	Migration     *MigrationState `json:"migration,omitempty"`
+	Pool          PoolStats       `json:"pool"`
+	Build         BuildInfo       `json:"build"`
+	StartedAt     time.Time       `json:"started_at"`
+	Uptime        string          `json:"uptime"`
+	UptimeSeconds float64         `json:"uptime_seconds"`
+}
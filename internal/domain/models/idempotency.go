@@ -0,0 +1,24 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrIdempotencyKeyNotValid   = errors.New("idempotency key must be from 1 to 255 characters")
+	ErrIdempotencyKeyMismatch   = errors.New("idempotency key was already used with another request")
+	ErrIdempotencyKeyInProgress = errors.New("request with this idempotency key is still in progress")
+)
+
+// IdempotentResponse сохранённый результат первого запроса с ключом идемпотентности.
+// LeaseToken выдаётся запросу, занявшему ключ, ответ сохраняется только с этим токеном.
+type IdempotentResponse struct {
+	Key         string
+	RequestHash string
+	LeaseToken  string
+	StatusCode  int
+	Headers     http.Header
+	Body        []byte
+	Completed   bool
+}
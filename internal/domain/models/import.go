@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+var (
+	ErrImportFormatNotValid = errors.New("import format must be csv or ndjson")
+	ErrStorageRequired      = errors.New("storage is required when count is set")
+	ErrCountRequired        = errors.New("count is required when storage is set")
+	ErrImportCountNotValid  = errors.New("count must not exceed 2147483647")
+	ErrRowsNotImported      = errors.New("one or more rows were not imported")
+)
+
+// ImportFormat формат файла импорта товаров.
+type ImportFormat string
+
+const (
+	ImportCSV    ImportFormat = "csv"
+	ImportNDJSON ImportFormat = "ndjson"
+)
+
+func ParseImportFormat(s string) (ImportFormat, error) {
+	switch f := ImportFormat(s); f {
+	case ImportCSV, ImportNDJSON:
+		return f, nil
+	default:
+		return "", ErrImportFormatNotValid
+	}
+}
+
+// ImportRow строка файла импорта, Count задаёт остаток товара на складе Storage.
+type ImportRow struct {
+	Line    int    `json:"-"`
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	Size    uint   `json:"size"`
+	Count   *uint  `json:"count"`
+	Storage *uint  `json:"storage"`
+}
+
+func (r ImportRow) Validate() error {
+	if err := (Product{Code: r.Code, Size: r.Size}).Validate(); err != nil {
+		return err
+	}
+	if l := utf8.RuneCountInString(r.Name); l == 0 || l > maxProductNameLength {
+		return ErrProductNameNotValid
+	}
+	if r.Count != nil && r.Storage == nil {
+		return ErrStorageRequired
+	}
+	if r.Storage != nil && r.Count == nil {
+		return ErrCountRequired
+	}
+	// остатки и склады хранятся в INT, одно значение вне диапазона прервало бы загрузку всей пачки
+	if r.Count != nil && *r.Count > math.MaxInt32 {
+		return ErrImportCountNotValid
+	}
+	if r.Storage != nil && *r.Storage > math.MaxInt32 {
+		return ErrStorageNotFound
+	}
+	return nil
+}
+
+// ImportError ошибка отдельной строки файла импорта.
+type ImportError struct {
+	Line  int    `json:"line"`
+	Code  string `json:"code,omitempty"`
+	Error string `json:"error"`
+}
+
+func NewImportError(line int, code string, err error) ImportError {
+	return ImportError{Line: line, Code: code, Error: err.Error()}
+}
+
+// ImportReport итог импорта товаров.
+type ImportReport struct {
+	Total    int           `json:"total"`
+	Imported int           `json:"imported"`
+	Failed   int           `json:"failed"`
+	Errors   []ImportError `json:"errors"`
+}
+
+// RowError ошибка разбора строки файла импорта, не прерывает импорт.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInboundNotFound        = errors.New("inbound shipment not found")
+	ErrInboundAlreadyReceived = errors.New("inbound shipment already received")
+	ErrInboundLinesEmpty      = errors.New("inbound shipment must contain at least one line")
+	ErrExpectedNotValid       = errors.New("expected quantity must be greater than zero")
+	ErrReceivedRequired       = errors.New("received quantity is required for every line")
+)
+
+// InboundStatus состояние поставки.
+type InboundStatus string
+
+const (
+	InboundExpected InboundStatus = "expected"
+	InboundReceived InboundStatus = "received"
+)
+
+// ReceiptResult результат приёмки строки поставки.
+type ReceiptResult string
+
+const (
+	ReceiptMatched    ReceiptResult = "matched"
+	ReceiptOver       ReceiptResult = "over_received"
+	ReceiptUnder      ReceiptResult = "under_received"
+	ReceiptUnexpected ReceiptResult = "unexpected"
+)
+
+// Inbound поставка товаров на склад StorageID.
+type Inbound struct {
+	ID         uint64        `json:"id"`
+	StorageID  uint          `json:"storage_id"`
+	Reference  string        `json:"reference,omitempty"`
+	Status     InboundStatus `json:"status"`
+	CreatedAt  time.Time     `json:"created_at"`
+	ReceivedAt *time.Time    `json:"received_at,omitempty"`
+	Lines      []InboundLine `json:"lines"`
+}
+
+// InboundLine строка поставки: Expected - ожидаемое количество, Received - фактически принятое,
+// заполняется при приёмке вместе с Difference и Result.
+type InboundLine struct {
+	ProductID  uint          `json:"product_id,omitempty"`
+	Code       string        `json:"code"`
+	Name       string        `json:"name,omitempty"`
+	Expected   uint          `json:"expected"`
+	Received   *uint         `json:"received,omitempty"`
+	Difference int           `json:"difference,omitempty"`
+	Result     ReceiptResult `json:"result,omitempty"`
+}
+
+func (i Inbound) Validate() error {
+	if i.StorageID == 0 {
+		return ErrNilStorageID
+	}
+	if len(i.Lines) == 0 {
+		return ErrInboundLinesEmpty
+	}
+	for _, line := range i.Lines {
+		if err := (Product{Code: line.Code}).Validate(); err != nil {
+			return err
+		}
+		if line.Expected == 0 {
+			return ErrExpectedNotValid
+		}
+	}
+	return nil
+}
+
+// ValidateReceipt проверяет строки приёмки, строки поставки без фактического количества считаются непринятыми.
+func ValidateReceipt(lines []InboundLine) error {
+	if len(lines) == 0 {
+		return ErrInboundLinesEmpty
+	}
+	for _, line := range lines {
+		if err := (Product{Code: line.Code}).Validate(); err != nil {
+			return err
+		}
+		if line.Received == nil {
+			return ErrReceivedRequired
+		}
+	}
+	return nil
+}
+
+// Reconcile сравнивает принятое количество с ожидаемым для принятой поставки.
+func (i *Inbound) Reconcile() {
+	if i.Status != InboundReceived {
+		return
+	}
+	for j := range i.Lines {
+		line := &i.Lines[j]
+		var received uint
+		if line.Received != nil {
+			received = *line.Received
+		}
+		line.Difference = int(received) - int(line.Expected)
+		switch {
+		case line.Expected == 0:
+			line.Result = ReceiptUnexpected
+		case line.Difference > 0:
+			line.Result = ReceiptOver
+		case line.Difference < 0:
+			line.Result = ReceiptUnder
+		default:
+			line.Result = ReceiptMatched
+		}
+	}
+}
+
+// Discrepancies сообщает, есть ли строки, принятые не в ожидаемом количестве.
+func (i Inbound) Discrepancies() bool {
+	for _, line := range i.Lines {
+		if line.Result != "" && line.Result != ReceiptMatched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package models
+
+// Item позиция запроса на резервирование или освобождение товара
+// вместе с результатом её обработки.
+type Item struct {
+	Product
+
+	Count     uint `json:"count,omitempty"`
+	Quantity  uint `json:"quantity,omitempty"`
+	Reserved  uint `json:"reserved,omitempty"`
+	Available uint `json:"available,omitempty"`
+	Released  uint `json:"released,omitempty"`
+
+	Allocations []Allocation `json:"allocations,omitempty"`
+
+	Status ItemStatus `json:"status,omitempty"`
+	Reason string     `json:"reason,omitempty"`
+}
+
+// RequestedQuantity возвращает запрошенное количество единиц товара,
+// если количество не указано, то резервируется одна единица.
+func (i Item) RequestedQuantity() uint {
+	if i.Quantity == 0 {
+		return 1
+	}
+	return i.Quantity
+}
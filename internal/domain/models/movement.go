@@ -0,0 +1,50 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrTimeRangeNotValid = errors.New("from must be RFC 3339 time not later than to")
+
+// MovementType причина изменения остатка.
+type MovementType string
+
+const (
+	MovementReceipt     MovementType = "receipt"
+	MovementReservation MovementType = "reservation"
+	MovementRelease     MovementType = "release"
+	MovementShipment    MovementType = "shipment"
+	MovementAdjustment  MovementType = "adjustment"
+	MovementTransfer    MovementType = "transfer"
+)
+
+// Movement запись журнала движения остатков: OnHandChange - изменение количества на складе,
+// ReservedChange - изменение количества в активных резервах.
+type Movement struct {
+	ID             uint64       `json:"id"`
+	Type           MovementType `json:"type"`
+	StorageID      uint         `json:"storage_id"`
+	ProductID      uint         `json:"product_id"`
+	Code           string       `json:"code"`
+	OnHandChange   int          `json:"on_hand_change"`
+	ReservedChange int          `json:"reserved_change"`
+	ReservationID  *uint64      `json:"reservation_id,omitempty"`
+	InboundID      *uint64      `json:"inbound_id,omitempty"`
+	TransferID     *uint64      `json:"transfer_id,omitempty"`
+	CountID        *uint64      `json:"count_id,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+// MovementFilter ограничивает выборку журнала полуинтервалом [From, To).
+type MovementFilter struct {
+	From *time.Time
+	To   *time.Time
+}
+
+func (f MovementFilter) Validate() error {
+	if f.From != nil && f.To != nil && f.From.After(*f.To) {
+		return ErrTimeRangeNotValid
+	}
+	return nil
+}
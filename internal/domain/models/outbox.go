@@ -0,0 +1,16 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEvent событие из outbox для шины сообщений. События с одинаковым Key (идентификатор товара)
+// публикуются в порядке записи.
+type OutboxEvent struct {
+	ID        uint64          `json:"id"`
+	Type      string          `json:"type"`
+	Key       string          `json:"key"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+// ItemStatus результат обработки отдельного товара из запроса.
+type ItemStatus string
+
+const (
+	ItemReserved          ItemStatus = "reserved"
+	ItemReleased          ItemStatus = "released"
+	ItemAlreadyReserved   ItemStatus = "already_reserved"
+	ItemNotFound          ItemStatus = "not_found"
+	ItemInvalidCode       ItemStatus = "invalid_code"
+	ItemInsufficientStock ItemStatus = "insufficient_stock"
+	ItemNotReserved       ItemStatus = "not_reserved"
+	ItemRolledBack        ItemStatus = "rolled_back"
+)
+
+// Машиночитаемые причины, по которым товар не был обработан.
+const (
+	ReasonCodeFormatMismatch     = "code_format_mismatch"
+	ReasonProductNotInCatalog    = "product_not_in_catalog"
+	ReasonAvailableLessRequested = "available_less_than_requested"
+	ReasonAllUnitsReserved       = "all_units_reserved"
+	ReasonNoActiveReservation    = "no_active_reservation"
+	ReasonAtomicOperationFailed  = "atomic_operation_failed"
+)
+
+// SetOutcome фиксирует результат обработки товара.
+func (i *Item) SetOutcome(status ItemStatus, reason string) {
+	i.Status = status
+	i.Reason = reason
+}
+
+// Succeeded сообщает, был ли товар зарезервирован или освобождён.
+func (i Item) Succeeded() bool {
+	return i.Status == ItemReserved || i.Status == ItemReleased
+}
@@ -2,26 +2,114 @@ package models
 
 import (
 	"errors"
+	"math"
 	"regexp"
+	"strings"
+	"unicode/utf8"
 )
 
-var ErrCodeNotValid = errors.New("code of product not valid")
+var (
+	ErrCodeNotValid           = errors.New("code of product not valid")
+	ErrProductsInUse          = errors.New("one or more products are already in use by another system")
+	ErrProductNotFound        = errors.New("product not found")
+	ErrProductNameNotValid    = errors.New("product name must be from 1 to 50 characters")
+	ErrProductSizeNotValid    = errors.New("product size must not exceed 32767")
+	ErrProductCodeExists      = errors.New("product with this code already exists")
+	ErrProductHasReservations = errors.New("product has active reservations")
+	ErrProductHasStock        = errors.New("product is still on hand in storages")
+	ErrProductHasHistory      = errors.New("product is referenced by reservation or stock movement history")
+	ErrEmptyProductPatch      = errors.New("nothing to update in product")
+	ErrPageNotValid           = errors.New("limit must be from 1 to 1000 and offset can't be negative")
+)
+
+const (
+	maxProductCodeLength = 32
+	maxProductNameLength = 50
+	// products.product_size SMALLINT
+	maxProductSize = math.MaxInt16
+)
+
+var productCodeRegexp = regexp.MustCompile("^[A-Z]{2}-[A-Z0-9]+$")
+
+// ProductsInUseError товары, которые в данный момент обрабатываются другим запросом.
+type ProductsInUseError struct {
+	Codes []string
+}
+
+func (e *ProductsInUseError) Error() string {
+	return ErrProductsInUse.Error() + ": " + strings.Join(e.Codes, ", ")
+}
 
+func (e *ProductsInUseError) Unwrap() error {
+	return ErrProductsInUse
+}
+
+// Product товар каталога, код товара уникален.
 type Product struct {
-	Code  string `json:"code"`
-	Name  string `json:"name,omitempty"`
-	ID    uint   `json:"id,omitempty"`
-	Size  uint   `json:"size,omitempty"`
-	Count uint   `json:"count,omitempty"`
+	Code string `json:"code"`
+	Name string `json:"name,omitempty"`
+	ID   uint   `json:"id,omitempty"`
+	Size uint   `json:"size,omitempty"`
 }
 
 func (p Product) Validate() error {
-	r, err := regexp.Compile("^[A-Z]{2}-[A-Z0-9]+$")
-	if err != nil {
+	if err := validateCode(p.Code); err != nil {
 		return err
 	}
-	if !r.MatchString(p.Code) {
+	if p.Size > maxProductSize {
+		return ErrProductSizeNotValid
+	}
+	return nil
+}
+
+func validateCode(code string) error {
+	if len(code) > maxProductCodeLength || !productCodeRegexp.MatchString(code) {
 		return ErrCodeNotValid
 	}
 	return nil
 }
+
+// ProductPatch изменяемые поля товара, незаполненные поля не изменяются.
+type ProductPatch struct {
+	Code *string `json:"code"`
+	Name *string `json:"name"`
+	Size *uint   `json:"size"`
+}
+
+func (p ProductPatch) Validate() error {
+	if p.Code == nil && p.Name == nil && p.Size == nil {
+		return ErrEmptyProductPatch
+	}
+	if p.Code != nil {
+		if err := validateCode(*p.Code); err != nil {
+			return err
+		}
+	}
+	if p.Name != nil {
+		if l := utf8.RuneCountInString(*p.Name); l == 0 || l > maxProductNameLength {
+			return ErrProductNameNotValid
+		}
+	}
+	if p.Size != nil && *p.Size > maxProductSize {
+		return ErrProductSizeNotValid
+	}
+	return nil
+}
+
+// NewProduct товар из запроса на создание, код и наименование обязательны.
+func (p ProductPatch) NewProduct() (Product, error) {
+	if p.Code == nil {
+		return Product{}, ErrCodeNotValid
+	}
+	if p.Name == nil {
+		return Product{}, ErrProductNameNotValid
+	}
+	if err := p.Validate(); err != nil {
+		return Product{}, err
+	}
+	product := Product{Code: *p.Code, Name: *p.Name}
+	if p.Size != nil {
+		product.Size = *p.Size
+	}
+	return product, nil
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrReservationNotFound  = errors.New("reservation not found")
+	ErrReservationNotActive = errors.New("reservation is not active")
+	ErrReservationOwner     = errors.New("reservation belongs to another owner")
+	ErrTTLNotValid          = errors.New("ttl of reservation must be a positive duration")
+	ErrModeNotValid         = errors.New("mode must be one of: atomic, best_effort")
+	ErrOperationRolledBack  = errors.New("operation was rolled back because not all products can be processed")
+	ErrStockNotOnHand       = errors.New("not enough stock on hand to fulfil reservation, goods may be in transit")
+	ErrReservationInTransit = errors.New("reservation items are moved by a transfer in transit, complete or cancel the transfer first")
+)
+
+// OperationMode режим обработки товаров в запросе на резервирование или освобождение.
+type OperationMode string
+
+const (
+	// ModeBestEffort обрабатывает все товары, которые возможно, и сообщает результат по каждому.
+	ModeBestEffort OperationMode = "best_effort"
+	// ModeAtomic откатывает операцию целиком, если хотя бы один товар не может быть обработан.
+	ModeAtomic OperationMode = "atomic"
+)
+
+// ParseOperationMode разбирает режим из запроса, пустое значение соответствует best_effort.
+func ParseOperationMode(mode string) (OperationMode, error) {
+	switch OperationMode(mode) {
+	case "", ModeBestEffort:
+		return ModeBestEffort, nil
+	case ModeAtomic:
+		return ModeAtomic, nil
+	}
+	return "", ErrModeNotValid
+}
+
+type ReservationStatus string
+
+const (
+	ReservationActive    ReservationStatus = "active"
+	ReservationReleased  ReservationStatus = "released"
+	ReservationFulfilled ReservationStatus = "fulfilled"
+	ReservationExpired   ReservationStatus = "expired"
+)
+
+type Reservation struct {
+	ID        uint64            `json:"id"`
+	Owner     string            `json:"owner"`
+	Status    ReservationStatus `json:"status"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	ExpiresAt *time.Time        `json:"expires_at,omitempty"`
+	Items     []ReservationItem `json:"items"`
+}
+
+// ReservationItem позиция резерва: сколько единиц товара удерживается на конкретном складе.
+type ReservationItem struct {
+	StorageID uint   `json:"storage_id"`
+	ProductID uint   `json:"product_id"`
+	Code      string `json:"code"`
+	Name      string `json:"name,omitempty"`
+	Quantity  uint   `json:"quantity"`
+}
+
+// ReservationOptions параметры запроса на резервирование.
+type ReservationOptions struct {
+	Owner string
+	// TTL время удержания резерва, нулевое значение означает бессрочный резерв.
+	TTL      time.Duration
+	Mode     OperationMode
+	Strategy AllocationStrategy
+}
+
+// ExemptionOptions параметры запроса на освобождение товаров.
+type ExemptionOptions struct {
+	Owner string
+	Mode  OperationMode
+}
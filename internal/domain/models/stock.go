@@ -0,0 +1,46 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrStorageNotFound    = errors.New("storage not found")
+	ErrStockBelowReserved = errors.New("quantity on hand can't be lower than reserved quantity")
+	ErrProductsNotFound   = errors.New("one or more products not found")
+)
+
+// Stock остаток товара на складе: OnHand - физически на складе, Reserved - в активных резервах,
+// Available - свободно для резервирования.
+type Stock struct {
+	StorageID uint   `json:"storage_id"`
+	ProductID uint   `json:"product_id"`
+	Code      string `json:"code"`
+	Name      string `json:"name,omitempty"`
+	Size      uint   `json:"size,omitempty"`
+	OnHand    uint   `json:"on_hand"`
+	Reserved  uint   `json:"reserved"`
+	Available uint   `json:"available"`
+}
+
+func (s Stock) Validate() error {
+	return Product{Code: s.Code}.Validate()
+}
+
+// StockBelowReservedError товары, остаток которых нельзя установить ниже зарезервированного количества.
+type StockBelowReservedError struct {
+	Stocks []Stock
+}
+
+func (e *StockBelowReservedError) Error() string {
+	codes := make([]string, 0, len(e.Stocks))
+	for _, stock := range e.Stocks {
+		codes = append(codes, stock.Code)
+	}
+	return ErrStockBelowReserved.Error() + ": " + strings.Join(codes, ", ")
+}
+
+func (e *StockBelowReservedError) Unwrap() error {
+	return ErrStockBelowReserved
+}
@@ -1,15 +1,28 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"unicode/utf8"
+)
 
 var (
-	ErrNilStorageID = errors.New("storage id can't be nil")
+	ErrNilStorageID           = errors.New("storage id can't be nil")
+	ErrStorageNameNotValid    = errors.New("storage name must be from 1 to 15 characters")
+	ErrStorageNameExists      = errors.New("storage with this name already exists")
+	ErrStorageHasReservations = errors.New("storage has active reservations")
+	ErrStorageHasStock        = errors.New("storage still has products on hand")
+	ErrStorageHasHistory      = errors.New("storage is referenced by reservation or stock movement history, disable it instead")
+	ErrEmptyStoragePatch      = errors.New("nothing to update in storage")
 )
 
+const maxStorageNameLength = 15
+
+// Storage склад, Priority задаёт порядок выбора склада при резервировании (меньшее значение раньше).
 type Storage struct {
-	ID      *uint  `json:"id"`
-	Name    string `json:"name,omitempty"`
-	Aviable bool   `json:"aviable,omitempty"`
+	ID       *uint  `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Aviable  bool   `json:"aviable"`
+	Priority int    `json:"priority"`
 }
 
 func (s Storage) Validate() error {
@@ -18,3 +31,40 @@ func (s Storage) Validate() error {
 	}
 	return nil
 }
+
+// StoragePatch изменяемые поля склада, незаполненные поля не изменяются.
+type StoragePatch struct {
+	Name     *string `json:"name"`
+	Aviable  *bool   `json:"aviable"`
+	Priority *int    `json:"priority"`
+}
+
+func (p StoragePatch) Validate() error {
+	if p.Name == nil && p.Aviable == nil && p.Priority == nil {
+		return ErrEmptyStoragePatch
+	}
+	if p.Name != nil {
+		if l := utf8.RuneCountInString(*p.Name); l == 0 || l > maxStorageNameLength {
+			return ErrStorageNameNotValid
+		}
+	}
+	return nil
+}
+
+// NewStorage склад из запроса на создание, по умолчанию склад доступен для резервирования.
+func (p StoragePatch) NewStorage() (Storage, error) {
+	if p.Name == nil {
+		return Storage{}, ErrStorageNameNotValid
+	}
+	if err := p.Validate(); err != nil {
+		return Storage{}, err
+	}
+	storage := Storage{Name: *p.Name, Aviable: true}
+	if p.Aviable != nil {
+		storage.Aviable = *p.Aviable
+	}
+	if p.Priority != nil {
+		storage.Priority = *p.Priority
+	}
+	return storage, nil
+}
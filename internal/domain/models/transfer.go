@@ -0,0 +1,110 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTransferNotFound          = errors.New("transfer not found")
+	ErrTransferNotInTransit      = errors.New("transfer is not in transit")
+	ErrTransferSameStorage       = errors.New("source and destination storages must differ")
+	ErrTransferLinesEmpty        = errors.New("transfer must contain at least one line")
+	ErrTransferQuantityNotValid  = errors.New("transfer quantity must be greater than zero")
+	ErrReservationPolicyNotValid = errors.New("reservations policy must be block or migrate")
+	ErrInsufficientStock         = errors.New("not enough stock in source storage")
+)
+
+// TransferStatus состояние перемещения, в пути товар не числится ни на одном складе.
+type TransferStatus string
+
+const (
+	TransferInTransit TransferStatus = "in_transit"
+	TransferCompleted TransferStatus = "completed"
+	TransferCancelled TransferStatus = "cancelled"
+)
+
+// ReservationPolicy определяет, что делать с резервами на складе-источнике:
+// block - перемещаются только свободные единицы, migrate - зарезервированные единицы
+// перемещаются вместе с позициями резервов.
+type ReservationPolicy string
+
+const (
+	ReservationsBlock   ReservationPolicy = "block"
+	ReservationsMigrate ReservationPolicy = "migrate"
+)
+
+// ParseReservationPolicy разбирает политику резервов, пустое значение означает block.
+func ParseReservationPolicy(s string) (ReservationPolicy, error) {
+	switch p := ReservationPolicy(s); p {
+	case "":
+		return ReservationsBlock, nil
+	case ReservationsBlock, ReservationsMigrate:
+		return p, nil
+	default:
+		return "", ErrReservationPolicyNotValid
+	}
+}
+
+// Transfer перемещение товаров между складами.
+type Transfer struct {
+	ID                   uint64            `json:"id"`
+	SourceStorageID      uint              `json:"source_storage_id"`
+	DestinationStorageID uint              `json:"destination_storage_id"`
+	Reservations         ReservationPolicy `json:"reservations"`
+	Status               TransferStatus    `json:"status"`
+	CreatedAt            time.Time         `json:"created_at"`
+	UpdatedAt            time.Time         `json:"updated_at"`
+	Lines                []TransferLine    `json:"lines"`
+}
+
+// TransferLine строка перемещения, Migrated - количество зарезервированных единиц,
+// перенесённых на склад назначения вместе с резервами.
+type TransferLine struct {
+	ProductID uint   `json:"product_id,omitempty"`
+	Code      string `json:"code"`
+	Name      string `json:"name,omitempty"`
+	Quantity  uint   `json:"quantity"`
+	Available uint   `json:"available,omitempty"`
+	Migrated  uint   `json:"migrated,omitempty"`
+}
+
+func (t Transfer) Validate() error {
+	if t.SourceStorageID == 0 || t.DestinationStorageID == 0 {
+		return ErrNilStorageID
+	}
+	if t.SourceStorageID == t.DestinationStorageID {
+		return ErrTransferSameStorage
+	}
+	if len(t.Lines) == 0 {
+		return ErrTransferLinesEmpty
+	}
+	for _, line := range t.Lines {
+		if err := (Product{Code: line.Code}).Validate(); err != nil {
+			return err
+		}
+		if line.Quantity == 0 {
+			return ErrTransferQuantityNotValid
+		}
+	}
+	return nil
+}
+
+// InsufficientStockError строки перемещения, для которых на складе-источнике недостаточно остатка,
+// Available - количество, которое можно переместить.
+type InsufficientStockError struct {
+	Lines []TransferLine
+}
+
+func (e *InsufficientStockError) Error() string {
+	codes := make([]string, 0, len(e.Lines))
+	for _, line := range e.Lines {
+		codes = append(codes, line.Code)
+	}
+	return ErrInsufficientStock.Error() + ": " + strings.Join(codes, ", ")
+}
+
+func (e *InsufficientStockError) Unwrap() error {
+	return ErrInsufficientStock
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"time"
+)
+
+var (
+	ErrWebhookNotFound        = errors.New("webhook not found")
+	ErrWebhookURLNotValid     = errors.New("webhook URL must be an absolute http or https URL")
+	ErrWebhookEventsNotValid  = errors.New("webhook must subscribe to at least one known event type")
+	ErrWebhookSecretRequired  = errors.New("webhook secret is required")
+	ErrDeliveryNotFound       = errors.New("webhook delivery not found")
+	ErrDeliveryNotDead        = errors.New("only dead deliveries can be retried")
+	ErrDeliveryStatusNotValid = errors.New("delivery status can only be pending, delivered or dead")
+)
+
+// EventType тип события, на которое можно подписаться.
+type EventType string
+
+const (
+	EventReservationCreated           EventType = "reservation.created"
+	EventReservationReleased          EventType = "reservation.released"
+	EventReservationPartiallyReleased EventType = "reservation.partially_released"
+	EventReservationExpired           EventType = "reservation.expired"
+	EventStockChanged                 EventType = "stock.changed"
+)
+
+func (t EventType) Validate() error {
+	switch t {
+	case EventReservationCreated, EventReservationReleased, EventReservationPartiallyReleased,
+		EventReservationExpired, EventStockChanged:
+		return nil
+	}
+	return ErrWebhookEventsNotValid
+}
+
+// Event событие для подписчиков, Data сериализуется в JSON как есть.
+type Event struct {
+	Type       EventType `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+func NewEvent(eventType EventType, data any) Event {
+	return Event{Type: eventType, OccurredAt: time.Now().UTC(), Data: data}
+}
+
+// StockChange данные события stock.changed: остатки каких товаров склада изменились и почему,
+// актуальные остатки подписчик запрашивает сам.
+type StockChange struct {
+	StorageID uint         `json:"storage_id"`
+	Reason    MovementType `json:"reason"`
+	Codes     []string     `json:"codes"`
+}
+
+// ExpiredReservation данные события reservation.expired.
+type ExpiredReservation struct {
+	ReservationID uint64 `json:"reservation_id"`
+}
+
+// Webhook подписка на события. Secret используется для подписи доставок и не возвращается в ответах.
+type Webhook struct {
+	ID        uint        `json:"id"`
+	URL       string      `json:"url"`
+	Events    []EventType `json:"events"`
+	Secret    string      `json:"secret,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+func (w Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrWebhookURLNotValid
+	}
+	if len(w.Events) == 0 {
+		return ErrWebhookEventsNotValid
+	}
+	for _, eventType := range w.Events {
+		if err := eventType.Validate(); err != nil {
+			return err
+		}
+	}
+	if w.Secret == "" {
+		return ErrWebhookSecretRequired
+	}
+	return nil
+}
+
+// DeliveryStatus состояние доставки события подписчику.
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryDead      DeliveryStatus = "dead"
+)
+
+func (s DeliveryStatus) Validate() error {
+	switch s {
+	case "", DeliveryPending, DeliveryDelivered, DeliveryDead:
+		return nil
+	}
+	return ErrDeliveryStatusNotValid
+}
+
+// WebhookDelivery доставка события подписчику. URL и Secret заполняются только для отправки.
+type WebhookDelivery struct {
+	ID             uint64          `json:"id"`
+	WebhookID      uint            `json:"webhook_id"`
+	EventType      EventType       `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	URL            string          `json:"-"`
+	Secret         string          `json:"-"`
+}
+
+// DeliveryFilter параметры выборки журнала доставок, нулевые WebhookID и Status не ограничивают выборку.
+type DeliveryFilter struct {
+	WebhookID uint
+	Status    DeliveryStatus
+	Limit     int
+	Offset    int
+}
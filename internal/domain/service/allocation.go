@@ -0,0 +1,152 @@
+package service
+
+import "github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+
+// StockLevels свободные остатки товаров по складам, изменяются по мере распределения.
+type StockLevels struct {
+	available     map[uint]map[uint]uint
+	reservedTotal map[uint]uint
+}
+
+func newStockLevels(levels []models.Stock) StockLevels {
+	stock := StockLevels{
+		available:     make(map[uint]map[uint]uint),
+		reservedTotal: make(map[uint]uint),
+	}
+	for _, level := range levels {
+		if _, ok := stock.available[level.StorageID]; !ok {
+			stock.available[level.StorageID] = make(map[uint]uint)
+		}
+		stock.available[level.StorageID][level.ProductID] = level.Available
+		stock.reservedTotal[level.ProductID] += level.Reserved
+	}
+	return stock
+}
+
+// Available свободный остаток товара на складе.
+func (s StockLevels) Available(storageID, productID uint) uint {
+	return s.available[storageID][productID]
+}
+
+// Take уменьшает свободный остаток товара на складе.
+func (s StockLevels) Take(storageID, productID, quantity uint) {
+	if products, ok := s.available[storageID]; ok {
+		products[productID] -= min(quantity, products[productID])
+	}
+}
+
+func (s StockLevels) total(productID uint) uint {
+	var total uint
+	for _, products := range s.available {
+		total += products[productID]
+	}
+	return total
+}
+
+func (s StockLevels) reserved(productID uint) uint {
+	return s.reservedTotal[productID]
+}
+
+// singleStorage выбирает один склад для всех товаров: первый по приоритету, который покрывает весь запрос,
+// иначе склад, покрывающий наибольшее число товаров.
+type singleStorage struct{}
+
+func (singleStorage) Allocate(storages []models.Storage, stock StockLevels, products []models.Item) {
+	var (
+		best       *models.Storage
+		bestFilled = -1
+	)
+	for i := range storages {
+		filled := fillable(*storages[i].ID, stock, products)
+		if filled > bestFilled {
+			best, bestFilled = &storages[i], filled
+		}
+		if filled == len(products) {
+			break
+		}
+	}
+	if best == nil || bestFilled == 0 {
+		return
+	}
+
+	for i := range products {
+		product := &products[i]
+		quantity := product.RequestedQuantity()
+		if product.ID == 0 || stock.Available(*best.ID, product.ID) < quantity {
+			continue
+		}
+		stock.Take(*best.ID, product.ID, quantity)
+		product.Allocations = []models.Allocation{{StorageID: *best.ID, Quantity: quantity}}
+	}
+}
+
+// fillable считает, сколько товаров склад может покрыть полностью с учётом повторов в запросе.
+func fillable(storageID uint, stock StockLevels, products []models.Item) int {
+	taken := make(map[uint]uint)
+	filled := 0
+	for _, product := range products {
+		quantity := product.RequestedQuantity()
+		if product.ID == 0 || stock.Available(storageID, product.ID) < taken[product.ID]+quantity {
+			continue
+		}
+		taken[product.ID] += quantity
+		filled++
+	}
+	return filled
+}
+
+// priorityStorage резервирует каждый товар целиком на первом по приоритету складе, где его достаточно.
+type priorityStorage struct{}
+
+func (priorityStorage) Allocate(storages []models.Storage, stock StockLevels, products []models.Item) {
+	for i := range products {
+		product := &products[i]
+		quantity := product.RequestedQuantity()
+		if product.ID == 0 {
+			continue
+		}
+		for _, storage := range storages {
+			if stock.Available(*storage.ID, product.ID) >= quantity {
+				stock.Take(*storage.ID, product.ID, quantity)
+				product.Allocations = []models.Allocation{{StorageID: *storage.ID, Quantity: quantity}}
+				break
+			}
+		}
+	}
+}
+
+// splitStorages набирает количество товара со складов в порядке приоритета.
+// Если суммарного остатка не хватает, товар не распределяется.
+type splitStorages struct{}
+
+func (splitStorages) Allocate(storages []models.Storage, stock StockLevels, products []models.Item) {
+	for i := range products {
+		product := &products[i]
+		quantity := product.RequestedQuantity()
+		if product.ID == 0 {
+			continue
+		}
+		var total uint
+		for _, storage := range storages {
+			total += stock.Available(*storage.ID, product.ID)
+		}
+		if total < quantity {
+			continue
+		}
+
+		allocations := make([]models.Allocation, 0, 1)
+		for _, storage := range storages {
+			if quantity == 0 {
+				break
+			}
+			take := min(quantity, stock.Available(*storage.ID, product.ID))
+			if take == 0 {
+				continue
+			}
+			stock.Take(*storage.ID, product.ID, take)
+			allocations = append(allocations, models.Allocation{StorageID: *storage.ID, Quantity: take})
+			quantity -= take
+		}
+		product.Allocations = allocations
+	}
+}
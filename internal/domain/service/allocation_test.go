@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+)
+
+func storages(ids ...uint) []models.Storage {
+	result := make([]models.Storage, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, models.Storage{ID: &id, Aviable: true})
+	}
+	return result
+}
+
+func item(productID, quantity uint) models.Item {
+	return models.Item{Product: models.Product{ID: productID}, Quantity: quantity}
+}
+
+func stock(storageID, productID, available uint) models.Stock {
+	return models.Stock{StorageID: storageID, ProductID: productID, Available: available}
+}
+
+func TestAllocators(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Allocator
+		levels   []models.Stock
+		products []models.Item
+		want     [][]models.Allocation
+	}{
+		{
+			name:     "single: first storage covering the whole request",
+			strategy: singleStorage{},
+			levels:   []models.Stock{stock(1, 10, 5), stock(2, 10, 5), stock(2, 11, 5)},
+			products: []models.Item{item(10, 2), item(11, 1)},
+			want:     [][]models.Allocation{{{StorageID: 2, Quantity: 2}}, {{StorageID: 2, Quantity: 1}}},
+		},
+		{
+			name:     "single: storage covering most products when none covers all",
+			strategy: singleStorage{},
+			levels:   []models.Stock{stock(1, 10, 5), stock(2, 11, 5), stock(2, 12, 5)},
+			products: []models.Item{item(10, 1), item(11, 1), item(12, 1)},
+			want:     [][]models.Allocation{nil, {{StorageID: 2, Quantity: 1}}, {{StorageID: 2, Quantity: 1}}},
+		},
+		{
+			name:     "single: tie is broken by storage priority",
+			strategy: singleStorage{},
+			levels:   []models.Stock{stock(1, 10, 1), stock(2, 11, 1)},
+			products: []models.Item{item(10, 1), item(11, 1)},
+			want:     [][]models.Allocation{{{StorageID: 1, Quantity: 1}}, nil},
+		},
+		{
+			name:     "single: repeated code is counted against one storage",
+			strategy: singleStorage{},
+			levels:   []models.Stock{stock(1, 10, 5), stock(2, 10, 6)},
+			products: []models.Item{item(10, 3), item(10, 3)},
+			want:     [][]models.Allocation{{{StorageID: 2, Quantity: 3}}, {{StorageID: 2, Quantity: 3}}},
+		},
+		{
+			name:     "single: zero quantity reserves one unit",
+			strategy: singleStorage{},
+			levels:   []models.Stock{stock(1, 10, 1)},
+			products: []models.Item{item(10, 0)},
+			want:     [][]models.Allocation{{{StorageID: 1, Quantity: 1}}},
+		},
+		{
+			name:     "single: nothing available",
+			strategy: singleStorage{},
+			levels:   []models.Stock{stock(1, 10, 0)},
+			products: []models.Item{item(10, 1), item(0, 1)},
+			want:     [][]models.Allocation{nil, nil},
+		},
+		{
+			name:     "priority: each product on the first storage with enough stock",
+			strategy: priorityStorage{},
+			levels:   []models.Stock{stock(1, 10, 1), stock(1, 11, 5), stock(2, 10, 5)},
+			products: []models.Item{item(10, 2), item(11, 2)},
+			want:     [][]models.Allocation{{{StorageID: 2, Quantity: 2}}, {{StorageID: 1, Quantity: 2}}},
+		},
+		{
+			name:     "priority: no partial fill",
+			strategy: priorityStorage{},
+			levels:   []models.Stock{stock(1, 10, 3), stock(2, 10, 3)},
+			products: []models.Item{item(10, 5)},
+			want:     [][]models.Allocation{nil},
+		},
+		{
+			name:     "priority: repeated code takes from the next storage",
+			strategy: priorityStorage{},
+			levels:   []models.Stock{stock(1, 10, 3), stock(2, 10, 2)},
+			products: []models.Item{item(10, 2), item(10, 2), item(10, 2)},
+			want:     [][]models.Allocation{{{StorageID: 1, Quantity: 2}}, {{StorageID: 2, Quantity: 2}}, nil},
+		},
+		{
+			name:     "priority: zero quantity and unknown product",
+			strategy: priorityStorage{},
+			levels:   []models.Stock{stock(1, 10, 0), stock(2, 10, 1)},
+			products: []models.Item{item(10, 0), item(0, 1)},
+			want:     [][]models.Allocation{{{StorageID: 2, Quantity: 1}}, nil},
+		},
+		{
+			name:     "split: partial fills in priority order",
+			strategy: splitStorages{},
+			levels:   []models.Stock{stock(1, 10, 3), stock(2, 10, 4)},
+			products: []models.Item{item(10, 5)},
+			want:     [][]models.Allocation{{{StorageID: 1, Quantity: 3}, {StorageID: 2, Quantity: 2}}},
+		},
+		{
+			name:     "split: empty storages are skipped",
+			strategy: splitStorages{},
+			levels:   []models.Stock{stock(1, 10, 0), stock(2, 10, 2), stock(3, 10, 2)},
+			products: []models.Item{item(10, 2)},
+			want:     [][]models.Allocation{{{StorageID: 2, Quantity: 2}}},
+		},
+		{
+			name:     "split: not allocated when the total is short",
+			strategy: splitStorages{},
+			levels:   []models.Stock{stock(1, 10, 3), stock(2, 10, 4)},
+			products: []models.Item{item(10, 8)},
+			want:     [][]models.Allocation{nil},
+		},
+		{
+			name:     "split: repeated code uses what is left",
+			strategy: splitStorages{},
+			levels:   []models.Stock{stock(1, 10, 3), stock(2, 10, 2)},
+			products: []models.Item{item(10, 4), item(10, 1), item(10, 1)},
+			want:     [][]models.Allocation{{{StorageID: 1, Quantity: 3}, {StorageID: 2, Quantity: 1}}, {{StorageID: 2, Quantity: 1}}, nil},
+		},
+		{
+			name:     "split: zero quantity reserves one unit",
+			strategy: splitStorages{},
+			levels:   []models.Stock{stock(1, 10, 0), stock(2, 10, 1)},
+			products: []models.Item{item(10, 0)},
+			want:     [][]models.Allocation{{{StorageID: 2, Quantity: 1}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.strategy.Allocate(storages(1, 2, 3), newStockLevels(tt.levels), tt.products)
+			for i, product := range tt.products {
+				if !reflect.DeepEqual(product.Allocations, tt.want[i]) {
+					t.Errorf("product %d allocations = %v, want %v", i, product.Allocations, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// storageRepo отдаёт заданные склады и остатки, остальные методы не используются.
+type storageRepo struct {
+	StorageRepo
+	storages []models.Storage
+	levels   []models.Stock
+}
+
+func (r storageRepo) FindAviableStorages(context.Context) ([]models.Storage, error) {
+	return r.storages, nil
+}
+
+func (r storageRepo) FindStockLevels(context.Context, []uint) ([]models.Stock, error) {
+	return r.levels, nil
+}
+
+func TestAllocateProductsOutcomes(t *testing.T) {
+	levels := []models.Stock{
+		{StorageID: 1, ProductID: 10, Available: 0, Reserved: 3},
+		{StorageID: 1, ProductID: 11, Available: 1},
+		{StorageID: 2, ProductID: 11, Available: 0, Reserved: 2},
+		{StorageID: 2, ProductID: 12, Available: 4},
+	}
+	s := NewStorageService(storageRepo{storages: storages(1, 2), levels: levels}, models.AllocationSplit)
+
+	products, err := s.AllocateProducts(context.Background(), "", []models.Item{
+		item(10, 1), item(11, 2), item(12, 2), item(13, 1), {Product: models.Product{Code: "XX-0"}},
+	})
+	if err != nil {
+		t.Fatalf("AllocateProducts() error = %v", err)
+	}
+
+	want := []struct {
+		status    models.ItemStatus
+		reason    string
+		available uint
+	}{
+		{models.ItemAlreadyReserved, models.ReasonAllUnitsReserved, 0},
+		// свободный остаток есть, хоть и меньше запрошенного, поэтому это нехватка, а не резерв
+		{models.ItemInsufficientStock, models.ReasonAvailableLessRequested, 1},
+		{"", "", 4},
+		{models.ItemInsufficientStock, models.ReasonAvailableLessRequested, 0},
+		{"", "", 0},
+	}
+	for i, product := range products {
+		if product.Status != want[i].status || product.Reason != want[i].reason || product.Available != want[i].available {
+			t.Errorf("product %d = %s/%s available %d, want %s/%s available %d",
+				i, product.Status, product.Reason, product.Available, want[i].status, want[i].reason, want[i].available)
+		}
+	}
+	if len(products[2].Allocations) != 1 || products[2].Allocations[0] != (models.Allocation{StorageID: 2, Quantity: 2}) {
+		t.Errorf("product 2 allocations = %v", products[2].Allocations)
+	}
+}
+
+func TestAllocateProductsUnknownStrategy(t *testing.T) {
+	s := NewStorageService(storageRepo{storages: storages(1)}, models.AllocationSingle)
+	if _, err := s.AllocateProducts(context.Background(), "nearest", []models.Item{item(10, 1)}); !errors.Is(err, ErrStrategyNotDefined) {
+		t.Fatalf("AllocateProducts() error = %v, want %v", err, ErrStrategyNotDefined)
+	}
+}
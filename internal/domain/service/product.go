@@ -8,6 +8,8 @@ import (
 
 	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
 	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/metrics"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/tracing"
 )
 
 var (
@@ -15,21 +17,40 @@ var (
 	ErrEmptyProducts = errors.New("lenght array of products is zero")
 )
 
+const (
+	defaultProductsLimit = 100
+	maxProductsLimit     = 1000
+)
+
 type ProductRepo interface {
-	FindProductsViaCode(ctx context.Context, products []models.Product) ([]models.Product, error)
-	FindProductsViaStorageID(ctx context.Context, storageID uint) ([]models.Product, error)
-	ExemptProducts(ctx context.Context, products []models.Product) error
+	FindProductsViaCode(ctx context.Context, products []models.Item) ([]models.Item, error)
+	ExemptProducts(ctx context.Context, opts models.ExemptionOptions, products []models.Item) ([]models.Item, error)
+	CreateProduct(ctx context.Context, product models.Product) (*models.Product, error)
+	FindProducts(ctx context.Context, limit, offset int) ([]models.Product, error)
+	FindProductViaCode(ctx context.Context, code string) (*models.Product, error)
+	UpdateProduct(ctx context.Context, code string, patch models.ProductPatch) (*models.Product, error)
+	DeleteProduct(ctx context.Context, code string) error
+	FindMovements(ctx context.Context, code string, filter models.MovementFilter) ([]models.Movement, error)
+}
+
+// AlertTrigger запрашивает внеочередную проверку остатков после их изменения.
+type AlertTrigger interface {
+	Trigger()
 }
+
 type productService struct {
 	repository ProductRepo
+	alerts     AlertTrigger
 }
 
-func NewProductService(pr ProductRepo) *productService {
-	return &productService{repository: pr}
+func NewProductService(pr ProductRepo, at AlertTrigger) *productService {
+	return &productService{repository: pr, alerts: at}
 }
 
-func (ps *productService) GetProductsInfo(ctx context.Context, products []models.Product) ([]models.Product, error) {
-	logger := logging.GetLogger()
+func (ps *productService) GetProductsInfo(ctx context.Context, products []models.Item) ([]models.Item, error) {
+	ctx, span := tracing.Start(ctx, "productService.GetProductsInfo")
+	defer span.End()
+	logger := logging.FromContext(ctx)
 	logger.Trace().Msg("start GetProductsInfo")
 
 	ctx, cancel := context.WithCancel(ctx)
@@ -51,34 +72,131 @@ func (ps *productService) GetProductsInfo(ctx context.Context, products []models
 	return products, nil
 }
 
-func (ps *productService) ProductExemption(ctx context.Context, products []models.Product) ([]models.Product, error) {
-	logger := logging.GetLogger()
+func (ps *productService) ProductExemption(ctx context.Context, opts models.ExemptionOptions, products []models.Item) ([]models.Item, error) {
+	ctx, span := tracing.Start(ctx, "productService.ProductExemption")
+	defer span.End()
+	logger := logging.FromContext(ctx)
 	logger.Trace().Msg("start ProductReservation")
 	ctx, cancel := context.WithTimeout(ctx, time.Second*6)
 	defer cancel()
+	metrics.ObserveBatch("exemption", len(products))
 
 	filledProducts, err := ps.GetProductsInfo(ctx, products)
 	if err != nil {
 		return nil, fmt.Errorf("GetProductsInfo failed: %w", err)
 	}
 
-	if err := ps.repository.ExemptProducts(ctx, filledProducts); err != nil {
-		return nil, fmt.Errorf("ExemptProducts failed: %w", err)
+	exemptedProducts, err := ps.repository.ExemptProducts(ctx, opts, filledProducts)
+	if err != nil {
+		return exemptedProducts, fmt.Errorf("ExemptProducts failed: %w", err)
 	}
+	ps.alerts.Trigger()
 
-	return filledProducts, nil
+	return exemptedProducts, nil
 }
 
-func (ps *productService) FindProducts(ctx context.Context, storageID uint) ([]models.Product, error) {
-	logger := logging.GetLogger()
-	logger.Trace().Msg("start FindProductIDs")
-	ctx, cancel := context.WithTimeout(ctx, time.Second*6)
-	defer cancel()
+func (ps *productService) CreateProduct(ctx context.Context, patch models.ProductPatch) (*models.Product, error) {
+	ctx, span := tracing.Start(ctx, "productService.CreateProduct")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CreateProduct")
+
+	product, err := patch.NewProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := ps.repository.CreateProduct(ctx, product)
+	if err != nil {
+		return nil, fmt.Errorf("CreateProduct failed: %w", err)
+	}
+
+	return created, nil
+}
+
+// GetProducts возвращает страницу каталога, отсортированного по коду товара,
+// нулевой limit заменяется значением по умолчанию.
+func (ps *productService) GetProducts(ctx context.Context, limit, offset int) ([]models.Product, error) {
+	ctx, span := tracing.Start(ctx, "productService.GetProducts")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start GetProducts")
+
+	if limit == 0 {
+		limit = defaultProductsLimit
+	}
+	if limit < 0 || limit > maxProductsLimit || offset < 0 {
+		return nil, models.ErrPageNotValid
+	}
 
-	products, err := ps.repository.FindProductsViaStorageID(ctx, storageID)
+	products, err := ps.repository.FindProducts(ctx, limit, offset)
 	if err != nil {
-		return nil, fmt.Errorf("FindProductsViaStorageID failed: %w", err)
+		return nil, fmt.Errorf("FindProducts failed: %w", err)
 	}
 
 	return products, nil
 }
+
+func (ps *productService) GetProduct(ctx context.Context, code string) (*models.Product, error) {
+	ctx, span := tracing.Start(ctx, "productService.GetProduct")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start GetProduct")
+
+	product, err := ps.repository.FindProductViaCode(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("FindProductViaCode failed: %w", err)
+	}
+
+	return product, nil
+}
+
+func (ps *productService) UpdateProduct(ctx context.Context, code string, patch models.ProductPatch) (*models.Product, error) {
+	ctx, span := tracing.Start(ctx, "productService.UpdateProduct")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start UpdateProduct")
+
+	if err := patch.Validate(); err != nil {
+		return nil, err
+	}
+
+	product, err := ps.repository.UpdateProduct(ctx, code, patch)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateProduct failed: %w", err)
+	}
+
+	return product, nil
+}
+
+func (ps *productService) DeleteProduct(ctx context.Context, code string) error {
+	ctx, span := tracing.Start(ctx, "productService.DeleteProduct")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start DeleteProduct")
+
+	if err := ps.repository.DeleteProduct(ctx, code); err != nil {
+		return fmt.Errorf("DeleteProduct failed: %w", err)
+	}
+
+	return nil
+}
+
+// GetMovements возвращает журнал движения остатков товара за период.
+func (ps *productService) GetMovements(ctx context.Context, code string, filter models.MovementFilter) ([]models.Movement, error) {
+	ctx, span := tracing.Start(ctx, "productService.GetMovements")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start GetMovements")
+
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	movements, err := ps.repository.FindMovements(ctx, code, filter)
+	if err != nil {
+		return nil, fmt.Errorf("FindMovements failed: %w", err)
+	}
+
+	return movements, nil
+}
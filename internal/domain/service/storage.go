@@ -7,43 +7,212 @@ import (
 
 	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
 	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/tracing"
 )
 
 var (
-	ErrNilStorageObj = errors.New("object of storage is null")
+	ErrNilStorageObj      = errors.New("object of storage is null")
+	ErrNoAviableStorages  = errors.New("there are no aviable storages")
+	ErrStrategyNotDefined = errors.New("allocation strategy is not registered")
 )
 
 type StorageRepo interface {
-	FindAviableStorage(ctx context.Context) (*models.Storage, error)
+	FindAviableStorages(ctx context.Context) ([]models.Storage, error)
+	FindStockLevels(ctx context.Context, productIDs []uint) ([]models.Stock, error)
+	CreateStorage(ctx context.Context, storage models.Storage) (*models.Storage, error)
+	FindStorages(ctx context.Context) ([]models.Storage, error)
+	FindStorageViaID(ctx context.Context, storageID uint) (*models.Storage, error)
+	UpdateStorage(ctx context.Context, storageID uint, patch models.StoragePatch) (*models.Storage, error)
+	DeleteStorage(ctx context.Context, storageID uint) error
+}
+
+// Allocator стратегия распределения товаров по складам. Склады передаются в порядке приоритета,
+// стратегия заполняет Allocations у товаров, которые может покрыть, и уменьшает остатки в stock.
+type Allocator interface {
+	Allocate(storages []models.Storage, stock StockLevels, products []models.Item)
 }
 
 type storageService struct {
-	repository StorageRepo
+	repository      StorageRepo
+	strategies      map[models.AllocationStrategy]Allocator
+	defaultStrategy models.AllocationStrategy
+}
+
+func NewStorageService(sr StorageRepo, defaultStrategy models.AllocationStrategy) *storageService {
+	return &storageService{
+		repository: sr,
+		strategies: map[models.AllocationStrategy]Allocator{
+			models.AllocationSingle:   singleStorage{},
+			models.AllocationPriority: priorityStorage{},
+			models.AllocationSplit:    splitStorages{},
+		},
+		defaultStrategy: defaultStrategy,
+	}
 }
 
-func NewStorageService(sr StorageRepo) *storageService {
-	return &storageService{repository: sr}
+// RegisterStrategy добавляет или заменяет стратегию распределения.
+func (s *storageService) RegisterStrategy(name models.AllocationStrategy, allocator Allocator) {
+	s.strategies[name] = allocator
 }
 
-func (s *storageService) GetAviableStorage(ctx context.Context) (*models.Storage, error) {
-	logger := logging.GetLogger()
-	logger.Trace().Msg("start GetAviableStorage")
+func (s *storageService) GetAviableStorages(ctx context.Context) ([]models.Storage, error) {
+	ctx, span := tracing.Start(ctx, "storageService.GetAviableStorages")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start GetAviableStorages")
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	storage, err := s.repository.FindAviableStorage(ctx)
+	storages, err := s.repository.FindAviableStorages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("FindAviableStorages failed: %w", err)
+	}
+
+	if len(storages) == 0 {
+		return nil, ErrNoAviableStorages
+	}
+
+	for _, storage := range storages {
+		if err := storage.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return storages, nil
+}
+
+// AllocateProducts распределяет товары по доступным складам выбранной стратегией.
+// Товарам, которые не удалось распределить, проставляется результат с причиной.
+func (s *storageService) AllocateProducts(ctx context.Context, strategy models.AllocationStrategy, products []models.Item) ([]models.Item, error) {
+	ctx, span := tracing.Start(ctx, "storageService.AllocateProducts")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start AllocateProducts")
+
+	if strategy == "" {
+		strategy = s.defaultStrategy
+	}
+	allocator, ok := s.strategies[strategy]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrStrategyNotDefined, strategy)
+	}
+
+	storages, err := s.GetAviableStorages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	productIDs := make([]uint, 0, len(products))
+	for _, product := range products {
+		if product.ID != 0 {
+			productIDs = append(productIDs, product.ID)
+		}
+	}
+	levels, err := s.repository.FindStockLevels(ctx, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("FindStockLevels failed: %w", err)
+	}
+	stock := newStockLevels(levels)
+
+	for i := range products {
+		products[i].Allocations = nil
+		products[i].Available = stock.total(products[i].ID)
+	}
+
+	allocator.Allocate(storages, stock, products)
+
+	for i := range products {
+		product := &products[i]
+		if product.ID == 0 || len(product.Allocations) > 0 {
+			continue
+		}
+		if product.Available == 0 && stock.reserved(product.ID) > 0 {
+			product.SetOutcome(models.ItemAlreadyReserved, models.ReasonAllUnitsReserved)
+		} else {
+			product.SetOutcome(models.ItemInsufficientStock, models.ReasonAvailableLessRequested)
+		}
+	}
+	logger.Debug().Str("strategy", string(strategy)).Any("products", products).Msg("products allocated")
+
+	return products, nil
+}
+
+func (s *storageService) CreateStorage(ctx context.Context, patch models.StoragePatch) (*models.Storage, error) {
+	ctx, span := tracing.Start(ctx, "storageService.CreateStorage")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CreateStorage")
+
+	storage, err := patch.NewStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := s.repository.CreateStorage(ctx, storage)
 	if err != nil {
-		return nil, fmt.Errorf("FindAviableStorage failed: %w", err)
+		return nil, fmt.Errorf("CreateStorage failed: %w", err)
 	}
 
-	if storage == nil {
-		return nil, ErrNilStorageObj
+	return created, nil
+}
+
+func (s *storageService) GetStorages(ctx context.Context) ([]models.Storage, error) {
+	ctx, span := tracing.Start(ctx, "storageService.GetStorages")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start GetStorages")
+
+	storages, err := s.repository.FindStorages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("FindStorages failed: %w", err)
+	}
+
+	return storages, nil
+}
+
+func (s *storageService) GetStorage(ctx context.Context, storageID uint) (*models.Storage, error) {
+	ctx, span := tracing.Start(ctx, "storageService.GetStorage")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start GetStorage")
+
+	storage, err := s.repository.FindStorageViaID(ctx, storageID)
+	if err != nil {
+		return nil, fmt.Errorf("FindStorageViaID failed: %w", err)
 	}
 
-	if err := storage.Validate(); err != nil {
+	return storage, nil
+}
+
+// UpdateStorage изменяет склад, в том числе его доступность для резервирования.
+func (s *storageService) UpdateStorage(ctx context.Context, storageID uint, patch models.StoragePatch) (*models.Storage, error) {
+	ctx, span := tracing.Start(ctx, "storageService.UpdateStorage")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start UpdateStorage")
+
+	if err := patch.Validate(); err != nil {
 		return nil, err
 	}
 
+	storage, err := s.repository.UpdateStorage(ctx, storageID, patch)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateStorage failed: %w", err)
+	}
+
 	return storage, nil
 }
+
+func (s *storageService) DeleteStorage(ctx context.Context, storageID uint) error {
+	ctx, span := tracing.Start(ctx, "storageService.DeleteStorage")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start DeleteStorage")
+
+	if err := s.repository.DeleteStorage(ctx, storageID); err != nil {
+		return fmt.Errorf("DeleteStorage failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/tracing"
+)
+
+const (
+	defaultAlertsLimit = 100
+	maxAlertsLimit     = 1000
+)
+
+type AlertRepo interface {
+	SetThreshold(ctx context.Context, code string, threshold models.Threshold) (*models.Threshold, error)
+	FindThresholds(ctx context.Context, code string) ([]models.Threshold, error)
+	DeleteThreshold(ctx context.Context, code string, storageID *uint) error
+	FindAlerts(ctx context.Context, filter models.AlertFilter) ([]models.StockAlert, error)
+}
+
+// AlertTrigger запрашивает внеочередную проверку остатков после их изменения.
+type AlertTrigger interface {
+	Trigger()
+}
+
+type alert struct {
+	repository AlertRepo
+	alerts     AlertTrigger
+}
+
+func NewAlert(r AlertRepo, at AlertTrigger) *alert {
+	return &alert{
+		repository: r,
+		alerts:     at,
+	}
+}
+
+// SetThreshold устанавливает порог пополнения товара.
+func (a *alert) SetThreshold(ctx context.Context, code string, threshold models.Threshold) (*models.Threshold, error) {
+	ctx, span := tracing.Start(ctx, "alert.SetThreshold")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start SetThreshold")
+
+	if err := threshold.Validate(); err != nil {
+		return nil, err
+	}
+
+	updated, err := a.repository.SetThreshold(ctx, code, threshold)
+	if err != nil {
+		return nil, fmt.Errorf("SetThreshold failed: %w", err)
+	}
+	a.alerts.Trigger()
+
+	return updated, nil
+}
+
+func (a *alert) GetThresholds(ctx context.Context, code string) ([]models.Threshold, error) {
+	ctx, span := tracing.Start(ctx, "alert.GetThresholds")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start GetThresholds")
+
+	thresholds, err := a.repository.FindThresholds(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("FindThresholds failed: %w", err)
+	}
+
+	return thresholds, nil
+}
+
+func (a *alert) DeleteThreshold(ctx context.Context, code string, storageID *uint) error {
+	ctx, span := tracing.Start(ctx, "alert.DeleteThreshold")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start DeleteThreshold")
+
+	if err := a.repository.DeleteThreshold(ctx, code, storageID); err != nil {
+		return fmt.Errorf("DeleteThreshold failed: %w", err)
+	}
+	a.alerts.Trigger()
+
+	return nil
+}
+
+// GetAlerts возвращает страницу предупреждений о низком остатке,
+// нулевой limit заменяется значением по умолчанию.
+func (a *alert) GetAlerts(ctx context.Context, filter models.AlertFilter) ([]models.StockAlert, error) {
+	ctx, span := tracing.Start(ctx, "alert.GetAlerts")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start GetAlerts")
+
+	if err := filter.Status.Validate(); err != nil {
+		return nil, err
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultAlertsLimit
+	}
+	if filter.Limit < 0 || filter.Limit > maxAlertsLimit || filter.Offset < 0 {
+		return nil, models.ErrPageNotValid
+	}
+
+	alerts, err := a.repository.FindAlerts(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("FindAlerts failed: %w", err)
+	}
+
+	return alerts, nil
+}
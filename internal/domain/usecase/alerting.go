@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+)
+
+type AlertingRepo interface {
+	EvaluateAlerts(ctx context.Context) ([]models.StockAlert, error)
+}
+
+// Notifier доставляет открытые и закрытые предупреждения о низком остатке.
+type Notifier interface {
+	Notify(ctx context.Context, alerts []models.StockAlert) error
+}
+
+// alerting фоновая проверка остатков по порогам пополнения. Проверка выполняется с заданным интервалом
+// и по запросу после изменения остатков, запросы во время проверки объединяются в одну следующую.
+type alerting struct {
+	repository AlertingRepo
+	notifier   Notifier
+	interval   time.Duration
+	trigger    chan struct{}
+}
+
+func NewAlerting(r AlertingRepo, n Notifier, interval time.Duration) *alerting {
+	return &alerting{
+		repository: r,
+		notifier:   n,
+		interval:   interval,
+		trigger:    make(chan struct{}, 1),
+	}
+}
+
+// Trigger запрашивает проверку остатков, не дожидаясь её выполнения.
+func (a *alerting) Trigger() {
+	select {
+	case a.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run выполняет проверки до отмены контекста. При неположительном интервале
+// проверки выполняются только по запросу.
+func (a *alerting) Run(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	logger.Info().Dur("interval", a.interval).Msg("start stock alerting worker")
+
+	var tick <-chan time.Time
+	if a.interval > 0 {
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	a.evaluate(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("stock alerting worker stopped")
+			return
+		case <-tick:
+			a.evaluate(ctx)
+		case <-a.trigger:
+			a.evaluate(ctx)
+		}
+	}
+}
+
+func (a *alerting) evaluate(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start evaluate")
+
+	alerts, err := a.repository.EvaluateAlerts(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("evaluation of stock alerts failed")
+		return
+	}
+	if len(alerts) == 0 {
+		return
+	}
+
+	// предупреждения уже сохранены и доступны через GET /alerts, поэтому ошибка доставки только логируется
+	if err := a.notifier.Notify(ctx, alerts); err != nil {
+		logger.Error().Err(err).Int("alerts", len(alerts)).Msg("notification of stock alerts failed")
+	}
+}
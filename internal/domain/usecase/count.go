@@ -0,0 +1,148 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/tracing"
+)
+
+type CountRepo interface {
+	CreateCount(ctx context.Context, storageID uint) (*models.InventoryCount, error)
+	FindCountViaID(ctx context.Context, countID uint64) (*models.InventoryCount, error)
+	SetCountLines(ctx context.Context, countID uint64, lines []models.CountLine) (*models.InventoryCount, error)
+	CommitCount(ctx context.Context, countID uint64) (*models.InventoryCount, error)
+	CancelCount(ctx context.Context, countID uint64) (*models.InventoryCount, error)
+}
+
+type count struct {
+	productService ProductService
+	repository     CountRepo
+	alerts         AlertTrigger
+}
+
+func NewCount(ps ProductService, r CountRepo, at AlertTrigger) *count {
+	return &count{
+		productService: ps,
+		repository:     r,
+		alerts:         at,
+	}
+}
+
+// OpenCount открывает пересчёт склада.
+func (c *count) OpenCount(ctx context.Context, storageID uint) (*models.InventoryCount, error) {
+	ctx, span := tracing.Start(ctx, "count.OpenCount")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start OpenCount")
+
+	if storageID == 0 {
+		return nil, models.ErrNilStorageID
+	}
+
+	opened, err := c.repository.CreateCount(ctx, storageID)
+	if err != nil {
+		return nil, fmt.Errorf("CreateCount failed: %w", err)
+	}
+
+	return opened, nil
+}
+
+// GetCount возвращает пересчёт с предварительными расхождениями.
+func (c *count) GetCount(ctx context.Context, countID uint64) (*models.InventoryCount, error) {
+	ctx, span := tracing.Start(ctx, "count.GetCount")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start GetCount")
+
+	found, err := c.repository.FindCountViaID(ctx, countID)
+	if err != nil {
+		return nil, fmt.Errorf("FindCountViaID failed: %w", err)
+	}
+
+	return found, nil
+}
+
+// SubmitCount сохраняет фактическое количество товаров. Возвращает список неизвестных кодов,
+// если хотя бы один товар не найден. Для повторяющихся товаров применяется последнее значение.
+func (c *count) SubmitCount(ctx context.Context, countID uint64, lines []models.CountLine) (*models.InventoryCount, []string, error) {
+	ctx, span := tracing.Start(ctx, "count.SubmitCount")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start SubmitCount")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*6)
+	defer cancel()
+
+	if err := models.ValidateCountLines(lines); err != nil {
+		return nil, nil, err
+	}
+
+	products := make([]models.Item, 0, len(lines))
+	for _, line := range lines {
+		products = append(products, models.Item{Product: models.Product{Code: line.Code}})
+	}
+	filledProducts, err := c.productService.GetProductsInfo(ctx, products)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetProductsInfo failed: %w", err)
+	}
+
+	notFound := make([]string, 0)
+	submitted := make([]models.CountLine, 0, len(lines))
+	index := make(map[uint]int, len(lines))
+	for i, product := range filledProducts {
+		if product.ID == 0 {
+			notFound = append(notFound, product.Code)
+			continue
+		}
+		lines[i].ProductID = product.ID
+		if j, ok := index[product.ID]; ok {
+			submitted[j] = lines[i]
+			continue
+		}
+		index[product.ID] = len(submitted)
+		submitted = append(submitted, lines[i])
+	}
+	if len(notFound) > 0 {
+		return nil, notFound, models.ErrProductsNotFound
+	}
+
+	updated, err := c.repository.SetCountLines(ctx, countID, submitted)
+	if err != nil {
+		return nil, nil, fmt.Errorf("SetCountLines failed: %w", err)
+	}
+
+	return updated, nil, nil
+}
+
+// CommitCount применяет пересчёт к остаткам склада.
+func (c *count) CommitCount(ctx context.Context, countID uint64) (*models.InventoryCount, error) {
+	ctx, span := tracing.Start(ctx, "count.CommitCount")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CommitCount")
+
+	committed, err := c.repository.CommitCount(ctx, countID)
+	if err != nil {
+		return nil, fmt.Errorf("CommitCount failed: %w", err)
+	}
+	c.alerts.Trigger()
+
+	return committed, nil
+}
+
+func (c *count) CancelCount(ctx context.Context, countID uint64) (*models.InventoryCount, error) {
+	ctx, span := tracing.Start(ctx, "count.CancelCount")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CancelCount")
+
+	cancelled, err := c.repository.CancelCount(ctx, countID)
+	if err != nil {
+		return nil, fmt.Errorf("CancelCount failed: %w", err)
+	}
+
+	return cancelled, nil
+}
@@ -0,0 +1,232 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/metrics"
+)
+
+const (
+	// dispatchBatchSize количество событий outbox, по которым доставки создаются одной транзакцией.
+	dispatchBatchSize = 100
+	// deliveryBatchSize количество доставок, отправляемых параллельно за один проход.
+	deliveryBatchSize = 20
+	// deliveryLease время, на которое взятая доставка скрывается от других обработчиков.
+	deliveryLease      = time.Minute * 5
+	maxDeliveryBackoff = time.Hour
+)
+
+type DeliveryRepo interface {
+	DispatchOutbox(ctx context.Context, limit int, build func([]models.OutboxEvent) ([]models.Event, error)) (int, error)
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery, retryIn time.Duration) error
+}
+
+type WebhookSender interface {
+	Send(ctx context.Context, delivery models.WebhookDelivery) (int, error)
+}
+
+// dispatcher фоновая доставка событий подписчикам. События строятся по событиям резервов и журналу движения
+// остатков из outbox, который записывается в транзакции изменения, поэтому событие не теряется ни при нагрузке, ни при остановке
+// сервиса. Неудачные попытки повторяются с экспоненциальной задержкой, после maxAttempts попыток доставка
+// помечается как dead.
+type dispatcher struct {
+	repository  DeliveryRepo
+	sender      WebhookSender
+	interval    time.Duration
+	backoff     time.Duration
+	maxAttempts int
+}
+
+func NewDispatcher(r DeliveryRepo, s WebhookSender, interval, backoff time.Duration, maxAttempts int) *dispatcher {
+	return &dispatcher{
+		repository:  r,
+		sender:      s,
+		interval:    interval,
+		backoff:     backoff,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Run создаёт и отправляет доставки с заданным интервалом до отмены контекста.
+func (d *dispatcher) Run(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	if d.interval <= 0 {
+		logger.Warn().Dur("interval", d.interval).Msg("webhook delivery worker disabled")
+		return
+	}
+	logger.Info().Dur("interval", d.interval).Int("max_attempts", d.maxAttempts).Msg("start webhook delivery worker")
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("webhook delivery worker stopped")
+			return
+		case <-ticker.C:
+			d.dispatch(ctx)
+			d.send(ctx)
+		}
+	}
+}
+
+// dispatch создаёт доставки по новым событиям outbox, пока они не закончатся.
+func (d *dispatcher) dispatch(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start dispatch")
+
+	for ctx.Err() == nil {
+		dispatched, err := d.repository.DispatchOutbox(ctx, dispatchBatchSize, d.events)
+		if err != nil {
+			logger.Error().Err(err).Msg("dispatch of outbox events failed")
+			return
+		}
+		if dispatched < dispatchBatchSize {
+			return
+		}
+	}
+}
+
+// events строит события для подписчиков по записям outbox: события резервов передаются с сохранённым
+// в outbox резервом, записи журнала движения, кроме резервирования и освобождения, - stock.changed
+// по складу и причине.
+func (d *dispatcher) events(outbox []models.OutboxEvent) ([]models.Event, error) {
+	type stockKey struct {
+		storageID uint
+		reason    models.MovementType
+	}
+	var (
+		events []models.Event
+		stock  = make(map[stockKey]int)
+		codes  = make(map[stockKey]map[string]bool)
+	)
+	for _, e := range outbox {
+		occurredAt := e.CreatedAt.UTC()
+
+		switch eventType := models.EventType(e.Type); eventType {
+		case models.EventReservationCreated, models.EventReservationReleased,
+			models.EventReservationPartiallyReleased, models.EventReservationExpired:
+			var reservation models.Reservation
+			if err := json.Unmarshal(e.Payload, &reservation); err != nil {
+				return nil, fmt.Errorf("unmarshal of outbox event %d failed: %w", e.ID, err)
+			}
+			event := models.Event{Type: eventType, OccurredAt: occurredAt, Data: &reservation}
+			if eventType == models.EventReservationExpired {
+				event.Data = models.ExpiredReservation{ReservationID: reservation.ID}
+			}
+			events = append(events, event)
+		default:
+			var movement models.Movement
+			if err := json.Unmarshal(e.Payload, &movement); err != nil {
+				return nil, fmt.Errorf("unmarshal of outbox event %d failed: %w", e.ID, err)
+			}
+			// резервирование и освобождение передаются событиями резервов
+			if movement.Type == models.MovementReservation || movement.Type == models.MovementRelease {
+				continue
+			}
+			key := stockKey{storageID: movement.StorageID, reason: movement.Type}
+			i, ok := stock[key]
+			if !ok {
+				i = len(events)
+				stock[key] = i
+				codes[key] = make(map[string]bool)
+				events = append(events, models.Event{
+					Type:       models.EventStockChanged,
+					OccurredAt: occurredAt,
+					Data:       models.StockChange{StorageID: key.storageID, Reason: key.reason, Codes: []string{}},
+				})
+			}
+			if codes[key][movement.Code] {
+				continue
+			}
+			codes[key][movement.Code] = true
+			change := events[i].Data.(models.StockChange)
+			change.Codes = append(change.Codes, movement.Code)
+			events[i].Data = change
+		}
+	}
+
+	return events, nil
+}
+
+// send отправляет доставки, время которых наступило, пока они не закончатся.
+func (d *dispatcher) send(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start send")
+
+	for ctx.Err() == nil {
+		deliveries, err := d.repository.ClaimDeliveries(ctx, deliveryBatchSize, deliveryLease)
+		if err != nil {
+			logger.Error().Err(err).Msg("claim of webhook deliveries failed")
+			return
+		}
+		if len(deliveries) > 0 {
+			metrics.ObserveBatch("webhook_delivery", len(deliveries))
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.attempt(ctx, delivery)
+			}()
+		}
+		wg.Wait()
+
+		if len(deliveries) < deliveryBatchSize {
+			return
+		}
+	}
+}
+
+// attempt отправляет доставку и записывает результат. Если результат записать не удалось,
+// доставка повторится по истечении deliveryLease.
+func (d *dispatcher) attempt(ctx context.Context, delivery models.WebhookDelivery) {
+	logger := logging.FromContext(ctx)
+
+	statusCode, err := d.sender.Send(ctx, delivery)
+	delivery.Attempts++
+	delivery.LastStatusCode = nil
+	if statusCode != 0 {
+		delivery.LastStatusCode = &statusCode
+	}
+	delivery.LastError = nil
+
+	var retryIn time.Duration
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliveryDelivered
+	case delivery.Attempts >= d.maxAttempts:
+		delivery.Status = models.DeliveryDead
+		reason := err.Error()
+		delivery.LastError = &reason
+		logger.Warn().Err(err).Uint64("delivery_id", delivery.ID).Uint("webhook_id", delivery.WebhookID).
+			Int("attempts", delivery.Attempts).Msg("webhook delivery moved to dead letters")
+	default:
+		delivery.Status = models.DeliveryPending
+		reason := err.Error()
+		delivery.LastError = &reason
+		retryIn = d.retryIn(delivery.Attempts)
+	}
+
+	if err := d.repository.UpdateDelivery(ctx, delivery, retryIn); err != nil {
+		logger.Error().Err(err).Uint64("delivery_id", delivery.ID).Msg("update of webhook delivery failed")
+	}
+}
+
+// retryIn задержка перед следующей попыткой: backoff, удваиваемый после каждой попытки.
+func (d *dispatcher) retryIn(attempts int) time.Duration {
+	wait := d.backoff
+	for i := 1; i < attempts && wait < maxDeliveryBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxDeliveryBackoff)
+}
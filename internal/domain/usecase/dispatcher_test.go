@@ -0,0 +1,237 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	sender "github.com/Shurubtsov/lamoda-test-task/internal/adapters/webhook"
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+)
+
+// deliveryRepo очередь доставок в памяти: ClaimDeliveries отдаёт доставки, время попытки которых наступило.
+type deliveryRepo struct {
+	mu         sync.Mutex
+	deliveries []models.WebhookDelivery
+	retries    []time.Duration
+	outbox     []models.OutboxEvent
+	dispatched []models.Event
+}
+
+func (r *deliveryRepo) DispatchOutbox(_ context.Context, limit int, build func([]models.OutboxEvent) ([]models.Event, error)) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	batch := r.outbox[:min(limit, len(r.outbox))]
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	events, err := build(batch)
+	if err != nil {
+		return 0, err
+	}
+	r.dispatched = append(r.dispatched, events...)
+	r.outbox = r.outbox[len(batch):]
+	return len(batch), nil
+}
+
+func (r *deliveryRepo) ClaimDeliveries(_ context.Context, limit int, _ time.Duration) ([]models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var claimed []models.WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.Status == models.DeliveryPending && !d.NextAttemptAt.After(time.Now()) && len(claimed) < limit {
+			claimed = append(claimed, d)
+		}
+	}
+	return claimed, nil
+}
+
+func (r *deliveryRepo) UpdateDelivery(_ context.Context, delivery models.WebhookDelivery, retryIn time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, d := range r.deliveries {
+		if d.ID == delivery.ID {
+			// повторная попытка назначается сразу, чтобы тест не ждал задержку
+			r.deliveries[i] = delivery
+			r.retries = append(r.retries, retryIn)
+		}
+	}
+	return nil
+}
+
+func (r *deliveryRepo) delivery(id uint64) models.WebhookDelivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.deliveries {
+		if d.ID == id {
+			return d
+		}
+	}
+	return models.WebhookDelivery{}
+}
+
+// subscriber отвечает 500 на первые failures запросов, затем 200, и проверяет подпись каждого запроса.
+func subscriber(t *testing.T, failures int) (*httptest.Server, *int) {
+	t.Helper()
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if r.Header.Get(sender.HeaderSignature) == "" || r.Header.Get(sender.HeaderDelivery) != "1" {
+			t.Errorf("delivery headers missing: %v", r.Header)
+		}
+		if requests <= failures {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func pendingDelivery(url string) models.WebhookDelivery {
+	return models.WebhookDelivery{
+		ID:        1,
+		WebhookID: 7,
+		EventType: models.EventStockChanged,
+		Payload:   json.RawMessage(`{"type":"stock.changed"}`),
+		Status:    models.DeliveryPending,
+		URL:       url,
+		Secret:    "s3cr3t",
+	}
+}
+
+func TestDispatcherRetriesUntilDelivered(t *testing.T) {
+	srv, requests := subscriber(t, 2)
+	repo := &deliveryRepo{deliveries: []models.WebhookDelivery{pendingDelivery(srv.URL)}}
+	d := NewDispatcher(repo, sender.NewSender(time.Second), time.Second, time.Second, 5)
+
+	for i := 0; i < 3; i++ {
+		d.send(context.Background())
+	}
+
+	delivery := repo.delivery(1)
+	if delivery.Status != models.DeliveryDelivered {
+		t.Fatalf("status = %s, want %s", delivery.Status, models.DeliveryDelivered)
+	}
+	if delivery.Attempts != 3 || *requests != 3 {
+		t.Fatalf("attempts = %d, requests = %d, want 3", delivery.Attempts, *requests)
+	}
+	if delivery.LastError != nil || delivery.LastStatusCode == nil || *delivery.LastStatusCode != http.StatusOK {
+		t.Fatalf("last result = %v, %v, want 200 without error", delivery.LastStatusCode, delivery.LastError)
+	}
+	want := []time.Duration{time.Second, time.Second * 2, 0}
+	if !reflect.DeepEqual(repo.retries, want) {
+		t.Fatalf("retries = %v, want %v", repo.retries, want)
+	}
+}
+
+func TestDispatcherDeadLetter(t *testing.T) {
+	srv, requests := subscriber(t, 10)
+	repo := &deliveryRepo{deliveries: []models.WebhookDelivery{pendingDelivery(srv.URL)}}
+	d := NewDispatcher(repo, sender.NewSender(time.Second), time.Second, time.Second, 3)
+
+	for i := 0; i < 5; i++ {
+		d.send(context.Background())
+	}
+
+	delivery := repo.delivery(1)
+	if delivery.Status != models.DeliveryDead {
+		t.Fatalf("status = %s, want %s", delivery.Status, models.DeliveryDead)
+	}
+	// после перевода в dead доставка больше не отправляется
+	if delivery.Attempts != 3 || *requests != 3 {
+		t.Fatalf("attempts = %d, requests = %d, want 3", delivery.Attempts, *requests)
+	}
+	if delivery.LastError == nil || delivery.LastStatusCode == nil || *delivery.LastStatusCode != http.StatusInternalServerError {
+		t.Fatalf("last result = %v, %v, want 500 with error", delivery.LastStatusCode, delivery.LastError)
+	}
+}
+
+func TestDispatcherRetryBackoff(t *testing.T) {
+	d := NewDispatcher(nil, nil, time.Second, time.Minute*20, 10)
+	for attempts, want := range map[int]time.Duration{1: time.Minute * 20, 2: time.Minute * 40, 3: time.Hour, 8: time.Hour} {
+		if got := d.retryIn(attempts); got != want {
+			t.Errorf("retryIn(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func outboxEvent(id uint64, eventType, payload string) models.OutboxEvent {
+	return models.OutboxEvent{ID: id, Type: eventType, Payload: json.RawMessage(payload), CreatedAt: time.Unix(1700000000, 0)}
+}
+
+func TestDispatcherEvents(t *testing.T) {
+	createdAt := time.Unix(1700000000, 0).UTC()
+	created := &models.Reservation{ID: 15, Owner: "orders", Status: models.ReservationActive, CreatedAt: createdAt, UpdatedAt: createdAt,
+		Items: []models.ReservationItem{{StorageID: 1, ProductID: 7, Code: "LK-7", Quantity: 2}}}
+	partial := &models.Reservation{ID: 17, Owner: "orders", Status: models.ReservationActive, CreatedAt: createdAt, UpdatedAt: createdAt,
+		Items: []models.ReservationItem{{StorageID: 2, ProductID: 8, Code: "RU-MOW", Quantity: 1}}}
+	released := &models.Reservation{ID: 15, Owner: "orders", Status: models.ReservationReleased, CreatedAt: createdAt, UpdatedAt: createdAt,
+		Items: []models.ReservationItem{}}
+	payload := func(reservation *models.Reservation) string {
+		data, err := json.Marshal(reservation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	repo := &deliveryRepo{
+		outbox: []models.OutboxEvent{
+			outboxEvent(1, "stock.reservation", `{"type": "reservation", "storage_id": 1, "code": "LK-7", "reservation_id": 15}`),
+			outboxEvent(2, "reservation.created", payload(created)),
+			outboxEvent(3, "stock.receipt", `{"type": "receipt", "storage_id": 1, "code": "LK-7"}`),
+			outboxEvent(4, "stock.receipt", `{"type": "receipt", "storage_id": 1, "code": "RU-MOW"}`),
+			outboxEvent(5, "stock.receipt", `{"type": "receipt", "storage_id": 1, "code": "LK-7"}`),
+			outboxEvent(6, "stock.release", `{"type": "release", "storage_id": 1, "code": "LK-7", "reservation_id": 15}`),
+			outboxEvent(7, "reservation.released", payload(released)),
+			outboxEvent(8, "reservation.partially_released", payload(partial)),
+			outboxEvent(9, "reservation.expired", `{"id": 16, "status": "expired", "items": []}`),
+			outboxEvent(10, "stock.shipment", `{"type": "shipment", "storage_id": 2, "code": "RU-MOW", "reservation_id": 18}`),
+		},
+	}
+	d := NewDispatcher(repo, nil, time.Second, time.Second, 3)
+
+	d.dispatch(context.Background())
+
+	occurredAt := time.Unix(1700000000, 0).UTC()
+	want := []models.Event{
+		{Type: models.EventReservationCreated, OccurredAt: occurredAt, Data: created},
+		{Type: models.EventStockChanged, OccurredAt: occurredAt, Data: models.StockChange{
+			StorageID: 1, Reason: models.MovementReceipt, Codes: []string{"LK-7", "RU-MOW"},
+		}},
+		{Type: models.EventReservationReleased, OccurredAt: occurredAt, Data: released},
+		{Type: models.EventReservationPartiallyReleased, OccurredAt: occurredAt, Data: partial},
+		{Type: models.EventReservationExpired, OccurredAt: occurredAt, Data: models.ExpiredReservation{ReservationID: 16}},
+		{Type: models.EventStockChanged, OccurredAt: occurredAt, Data: models.StockChange{
+			StorageID: 2, Reason: models.MovementShipment, Codes: []string{"RU-MOW"},
+		}},
+	}
+	if !reflect.DeepEqual(repo.dispatched, want) {
+		t.Fatalf("events = %+v, want %+v", repo.dispatched, want)
+	}
+	if len(repo.outbox) != 0 {
+		t.Fatalf("%d outbox events left undispatched", len(repo.outbox))
+	}
+}
+
+func TestDispatcherEventsKeepOutboxOnFailure(t *testing.T) {
+	repo := &deliveryRepo{
+		outbox: []models.OutboxEvent{outboxEvent(1, "reservation.created", `{"id": "15"}`)},
+	}
+	d := NewDispatcher(repo, nil, time.Second, time.Second, 3)
+
+	d.dispatch(context.Background())
+
+	if len(repo.outbox) != 1 || len(repo.dispatched) != 0 {
+		t.Fatalf("outbox = %d, dispatched = %d, want the event kept for the next pass", len(repo.outbox), len(repo.dispatched))
+	}
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/metrics"
+)
+
+// expirationBatchSize количество резервов, обрабатываемых за один проход.
+const expirationBatchSize = 100
+
+type ExpirationRepo interface {
+	ExpireReservations(ctx context.Context, limit int) ([]uint64, error)
+}
+
+// expiration фоновый обработчик, освобождающий резервы с истёкшим временем удержания.
+type expiration struct {
+	repository ExpirationRepo
+	alerts     AlertTrigger
+	interval   time.Duration
+}
+
+func NewExpiration(r ExpirationRepo, at AlertTrigger, interval time.Duration) *expiration {
+	return &expiration{
+		repository: r,
+		alerts:     at,
+		interval:   interval,
+	}
+}
+
+// Run запускает обработку истёкших резервов с заданным интервалом до отмены контекста.
+func (e *expiration) Run(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	if e.interval <= 0 {
+		logger.Warn().Dur("interval", e.interval).Msg("reservation expiration worker disabled")
+		return
+	}
+	logger.Info().Dur("interval", e.interval).Msg("start reservation expiration worker")
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("reservation expiration worker stopped")
+			return
+		case <-ticker.C:
+			e.expire(ctx)
+		}
+	}
+}
+
+func (e *expiration) expire(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start expire")
+
+	// пачки обрабатываются пока истёкшие резервы не закончатся
+	for {
+		expired, err := e.repository.ExpireReservations(ctx, expirationBatchSize)
+		if err != nil {
+			logger.Error().Err(err).Msg("expiration of reservations failed")
+			return
+		}
+		if len(expired) > 0 {
+			metrics.ObserveBatch("expiration", len(expired))
+			logger.Info().Any("reservations", expired).Msg("reservations expired")
+			e.alerts.Trigger()
+		}
+		if len(expired) < expirationBatchSize {
+			return
+		}
+	}
+}
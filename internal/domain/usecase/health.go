@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+)
+
+type HealthRepo interface {
+	Ping(ctx context.Context) error
+	FindMigrationState(ctx context.Context) (*models.MigrationState, error)
+	PoolStats() models.PoolStats
+}
+
+// health проверки состояния сервиса для оркестратора. Готовность снимается в начале остановки,
+// чтобы трафик перестал поступать до закрытия пула соединений.
+type health struct {
+	repository       HealthRepo
+	migrationVersion uint
+	startedAt        time.Time
+	build            models.BuildInfo
+	shuttingDown     atomic.Bool
+}
+
+func NewHealth(r HealthRepo, migrationVersion uint) *health {
+	return &health{
+		repository:       r,
+		migrationVersion: migrationVersion,
+		startedAt:        time.Now(),
+		build:            readBuildInfo(),
+	}
+}
+
+// Shutdown переводит сервис в состояние остановки, после чего он перестаёт быть готовым.
+func (h *health) Shutdown() {
+	if h.shuttingDown.CompareAndSwap(false, true) {
+		logging.GetLogger().Info().Msg("readiness disabled, service is shutting down")
+	}
+}
+
+// Ready проверяет соединение с бд, версию миграций и отсутствие остановки.
+func (h *health) Ready(ctx context.Context) models.Readiness {
+	readiness, _ := h.ready(ctx)
+	return readiness
+}
+
+// Status возвращает подробное состояние сервиса вместе с результатами проверок готовности.
+func (h *health) Status(ctx context.Context) models.ServiceStatus {
+	readiness, migration := h.ready(ctx)
+	uptime := time.Since(h.startedAt)
+	return models.ServiceStatus{
+		Readiness:     readiness,
+		Migration:     migration,
+		Pool:          h.repository.PoolStats(),
+		Build:         h.build,
+		StartedAt:     h.startedAt,
+		Uptime:        uptime.Round(time.Second).String(),
+		UptimeSeconds: uptime.Seconds(),
+	}
+}
+
+func (h *health) ready(ctx context.Context) (models.Readiness, *models.MigrationState) {
+	logger := logging.FromContext(ctx)
+	checks := make(map[string]error, 3)
+
+	checks["shutdown"] = nil
+	if h.shuttingDown.Load() {
+		checks["shutdown"] = models.ErrShuttingDown
+	}
+
+	checks["database"] = h.repository.Ping(ctx)
+
+	migration, err := h.repository.FindMigrationState(ctx)
+	switch {
+	case err != nil:
+		checks["migrations"] = err
+	case migration.Dirty:
+		checks["migrations"] = models.ErrMigrationDirty
+	case migration.Version != h.migrationVersion:
+		checks["migrations"] = fmt.Errorf("%w: applied %d, expected %d", models.ErrMigrationVersionMismatch, migration.Version, h.migrationVersion)
+	default:
+		checks["migrations"] = nil
+	}
+	if migration != nil {
+		migration.Expected = h.migrationVersion
+	}
+
+	readiness := models.Readiness{Ready: true, Checks: make(map[string]string, len(checks))}
+	for name, err := range checks {
+		if err != nil {
+			logger.Warn().Err(err).Str("check", name).Msg("readiness check failed")
+			readiness.Ready = false
+			readiness.Checks[name] = err.Error()
+			continue
+		}
+		readiness.Checks[name] = models.CheckOK
+	}
+	return readiness, migration
+}
+
+func readBuildInfo() models.BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return models.BuildInfo{}
+	}
+	build := models.BuildInfo{
+		GoVersion: info.GoVersion,
+		Module:    info.Main.Path,
+		Version:   info.Main.Version,
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Revision = setting.Value
+		case "vcs.time":
+			build.Time = setting.Value
+		case "vcs.modified":
+			build.Modified = setting.Value == "true"
+		}
+	}
+	return build
+}
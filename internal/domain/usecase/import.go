@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/metrics"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/tracing"
+)
+
+type (
+	ImportRepo interface {
+		ImportProducts(ctx context.Context, rows []models.ImportRow) ([]models.ImportError, error)
+	}
+
+	// RowReader источник строк импорта, см. importer.Reader.
+	RowReader interface {
+		Next() (models.ImportRow, error)
+	}
+)
+
+type importer struct {
+	repository ImportRepo
+	alerts     AlertTrigger
+	batchSize  int
+}
+
+func NewImport(r ImportRepo, at AlertTrigger, batchSize int) *importer {
+	return &importer{
+		repository: r,
+		alerts:     at,
+		batchSize:  batchSize,
+	}
+}
+
+// Import проверяет строки и загружает их пачками по batchSize, ошибочные строки
+// попадают в отчёт и не прерывают импорт. При ошибке базы данных импорт останавливается,
+// уже загруженные пачки сохраняются и учтены в отчёте.
+func (i *importer) Import(ctx context.Context, reader RowReader) (*models.ImportReport, error) {
+	ctx, span := tracing.Start(ctx, "importer.Import")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start Import")
+
+	report := &models.ImportReport{Errors: make([]models.ImportError, 0)}
+	batch := make([]models.ImportRow, 0, i.batchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		metrics.ObserveBatch("import", len(batch))
+		failed, err := i.repository.ImportProducts(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("ImportProducts failed: %w", err)
+		}
+		i.alerts.Trigger()
+		report.Imported += len(batch) - len(failed)
+		report.Failed += len(failed)
+		report.Errors = append(report.Errors, failed...)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		row, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rowErr *models.RowError
+		if errors.As(err, &rowErr) {
+			report.Total++
+			report.Failed++
+			report.Errors = append(report.Errors, models.NewImportError(rowErr.Line, row.Code, rowErr.Err))
+			continue
+		}
+		if err != nil {
+			return report, fmt.Errorf("read import rows: %w", err)
+		}
+
+		report.Total++
+		if err := row.Validate(); err != nil {
+			report.Failed++
+			report.Errors = append(report.Errors, models.NewImportError(row.Line, row.Code, err))
+			continue
+		}
+
+		batch = append(batch, row)
+		if len(batch) >= i.batchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return report, err
+	}
+
+	logger.Info().Int("total", report.Total).Int("imported", report.Imported).Int("failed", report.Failed).Msg("import finished")
+
+	return report, nil
+}
@@ -0,0 +1,139 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/tracing"
+)
+
+type InboundRepo interface {
+	CreateInbound(ctx context.Context, inbound models.Inbound) (*models.Inbound, error)
+	FindInboundViaID(ctx context.Context, inboundID uint64) (*models.Inbound, error)
+	ReceiveInbound(ctx context.Context, inboundID uint64, lines []models.InboundLine) (*models.Inbound, error)
+}
+
+type inbound struct {
+	productService ProductService
+	repository     InboundRepo
+	alerts         AlertTrigger
+}
+
+func NewInbound(ps ProductService, r InboundRepo, at AlertTrigger) *inbound {
+	return &inbound{
+		productService: ps,
+		repository:     r,
+		alerts:         at,
+	}
+}
+
+// RegisterInbound регистрирует ожидаемую поставку. Возвращает список неизвестных кодов,
+// если хотя бы один товар не найден.
+func (i *inbound) RegisterInbound(ctx context.Context, shipment models.Inbound) (*models.Inbound, []string, error) {
+	ctx, span := tracing.Start(ctx, "inbound.RegisterInbound")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start RegisterInbound")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*6)
+	defer cancel()
+
+	if err := shipment.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	notFound, err := i.resolveProducts(ctx, shipment.Lines)
+	if err != nil {
+		return nil, notFound, err
+	}
+
+	created, err := i.repository.CreateInbound(ctx, shipment)
+	if err != nil {
+		return nil, nil, fmt.Errorf("CreateInbound failed: %w", err)
+	}
+
+	return created, nil, nil
+}
+
+func (i *inbound) GetInbound(ctx context.Context, inboundID uint64) (*models.Inbound, error) {
+	ctx, span := tracing.Start(ctx, "inbound.GetInbound")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start GetInbound")
+
+	shipment, err := i.repository.FindInboundViaID(ctx, inboundID)
+	if err != nil {
+		return nil, fmt.Errorf("FindInboundViaID failed: %w", err)
+	}
+
+	return shipment, nil
+}
+
+// ReceiveInbound принимает поставку с фактическим количеством товаров,
+// повторяющиеся в приёмке товары суммируются.
+func (i *inbound) ReceiveInbound(ctx context.Context, inboundID uint64, lines []models.InboundLine) (*models.Inbound, []string, error) {
+	ctx, span := tracing.Start(ctx, "inbound.ReceiveInbound")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start ReceiveInbound")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*6)
+	defer cancel()
+
+	if err := models.ValidateReceipt(lines); err != nil {
+		return nil, nil, err
+	}
+
+	notFound, err := i.resolveProducts(ctx, lines)
+	if err != nil {
+		return nil, notFound, err
+	}
+
+	merged := make([]models.InboundLine, 0, len(lines))
+	index := make(map[uint]int, len(lines))
+	for _, line := range lines {
+		if j, ok := index[line.ProductID]; ok {
+			*merged[j].Received += *line.Received
+			continue
+		}
+		received := *line.Received
+		line.Received = &received
+		index[line.ProductID] = len(merged)
+		merged = append(merged, line)
+	}
+
+	received, err := i.repository.ReceiveInbound(ctx, inboundID, merged)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ReceiveInbound failed: %w", err)
+	}
+	i.alerts.Trigger()
+
+	return received, nil, nil
+}
+
+// resolveProducts заполняет идентификаторы товаров строк поставки по их кодам.
+func (i *inbound) resolveProducts(ctx context.Context, lines []models.InboundLine) ([]string, error) {
+	products := make([]models.Item, 0, len(lines))
+	for _, line := range lines {
+		products = append(products, models.Item{Product: models.Product{Code: line.Code}})
+	}
+	filledProducts, err := i.productService.GetProductsInfo(ctx, products)
+	if err != nil {
+		return nil, fmt.Errorf("GetProductsInfo failed: %w", err)
+	}
+
+	notFound := make([]string, 0)
+	for j, product := range filledProducts {
+		if product.ID == 0 {
+			notFound = append(notFound, product.Code)
+			continue
+		}
+		lines[j].ProductID = product.ID
+	}
+	if len(notFound) > 0 {
+		return notFound, models.ErrProductsNotFound
+	}
+
+	return nil, nil
+}
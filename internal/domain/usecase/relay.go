@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/metrics"
+)
+
+type OutboxRepo interface {
+	RelayOutbox(ctx context.Context, limit int, publish func([]models.OutboxEvent) error) (int, error)
+}
+
+// Publisher публикует пачку событий в шину сообщений, ошибка означает, что пачка будет опубликована повторно.
+type Publisher interface {
+	Publish(ctx context.Context, events []models.OutboxEvent) error
+}
+
+// relay фоновая публикация событий outbox. События публикуются пачками в порядке записи,
+// при ошибке публикация останавливается до следующего интервала, поэтому порядок событий
+// одного товара сохраняется, а доставка выполняется не менее одного раза.
+type relay struct {
+	repository OutboxRepo
+	publisher  Publisher
+	interval   time.Duration
+	batchSize  int
+}
+
+func NewRelay(r OutboxRepo, p Publisher, interval time.Duration, batchSize int) *relay {
+	return &relay{
+		repository: r,
+		publisher:  p,
+		interval:   interval,
+		batchSize:  batchSize,
+	}
+}
+
+// Run публикует события с заданным интервалом до отмены контекста.
+func (r *relay) Run(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	if r.interval <= 0 {
+		logger.Warn().Dur("interval", r.interval).Msg("outbox relay disabled")
+		return
+	}
+	logger.Info().Dur("interval", r.interval).Int("batch_size", r.batchSize).Msg("start outbox relay")
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("outbox relay stopped")
+			return
+		case <-ticker.C:
+			r.relay(ctx)
+		}
+	}
+}
+
+func (r *relay) relay(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start relay")
+
+	publish := func(events []models.OutboxEvent) error {
+		return r.publisher.Publish(ctx, events)
+	}
+	for ctx.Err() == nil {
+		published, err := r.repository.RelayOutbox(ctx, r.batchSize, publish)
+		if err != nil {
+			logger.Error().Err(err).Msg("relay of outbox events failed")
+			return
+		}
+		if published > 0 {
+			metrics.ObserveBatch("outbox", published)
+			logger.Debug().Int("events", published).Msg("outbox events published")
+		}
+		if published < r.batchSize {
+			return
+		}
+	}
+}
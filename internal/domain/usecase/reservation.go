@@ -7,52 +7,171 @@ import (
 
 	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
 	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/metrics"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/tracing"
 )
 
 type (
 	Repo interface {
-		ReserveProducts(ctx context.Context, storage models.Storage, products []models.Product) error
+		ReserveProducts(ctx context.Context, opts models.ReservationOptions, products []models.Item) (*models.Reservation, []models.Item, error)
+		FindReservationViaID(ctx context.Context, reservationID uint64) (*models.Reservation, error)
+		SetReservationStatus(ctx context.Context, reservationID uint64, status models.ReservationStatus) error
+		FulfilReservation(ctx context.Context, reservationID uint64) error
+		ExtendReservation(ctx context.Context, reservationID uint64, ttl time.Duration) error
 	}
 	StorageService interface {
-		GetAviableStorage(ctx context.Context) (*models.Storage, error)
+		AllocateProducts(ctx context.Context, strategy models.AllocationStrategy, products []models.Item) ([]models.Item, error)
 	}
 	ProductService interface {
-		GetProductsInfo(ctx context.Context, products []models.Product) ([]models.Product, error)
+		GetProductsInfo(ctx context.Context, products []models.Item) ([]models.Item, error)
 	}
 )
 type reservation struct {
 	storageService StorageService
 	productService ProductService
 	repository     Repo
+	alerts         AlertTrigger
+	defaultTTL     time.Duration
 }
 
-func NewReservation(ss StorageService, ps ProductService, r Repo) *reservation {
+// NewReservation создаёт юзкейс резервирования, defaultTTL применяется к резервам,
+// для которых время удержания не передано в запросе.
+func NewReservation(ss StorageService, ps ProductService, r Repo, at AlertTrigger, defaultTTL time.Duration) *reservation {
 	return &reservation{
 		storageService: ss,
 		productService: ps,
 		repository:     r,
+		alerts:         at,
+		defaultTTL:     defaultTTL,
 	}
 }
 
-func (r *reservation) ProductReservation(ctx context.Context, products []models.Product) ([]models.Product, error) {
-	logger := logging.GetLogger()
+func (r *reservation) ProductReservation(ctx context.Context, opts models.ReservationOptions, products []models.Item) (*models.Reservation, []models.Item, error) {
+	ctx, span := tracing.Start(ctx, "reservation.ProductReservation")
+	defer span.End()
+	logger := logging.FromContext(ctx)
 	logger.Trace().Msg("start ProductReservation")
 	ctx, cancel := context.WithTimeout(ctx, time.Second*6)
 	defer cancel()
 
-	storage, err := r.storageService.GetAviableStorage(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("GetAviableStorage failed: %w", err)
+	if opts.TTL < 0 {
+		return nil, nil, models.ErrTTLNotValid
+	}
+	if opts.TTL == 0 {
+		opts.TTL = r.defaultTTL
 	}
+	metrics.ObserveBatch("reservation", len(products))
 
 	filledProducts, err := r.productService.GetProductsInfo(ctx, products)
 	if err != nil {
-		return nil, fmt.Errorf("GetProductsInfo failed: %w", err)
+		return nil, nil, fmt.Errorf("GetProductsInfo failed: %w", err)
+	}
+
+	allocatedProducts, err := r.storageService.AllocateProducts(ctx, opts.Strategy, filledProducts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("AllocateProducts failed: %w", err)
+	}
+
+	reservation, reservedProducts, err := r.repository.ReserveProducts(ctx, opts, allocatedProducts)
+	if err != nil {
+		// при откате атомарного резерва возвращаем результат по каждому товару
+		return nil, reservedProducts, fmt.Errorf("ReserveProducts failed: %w", err)
+	}
+	r.alerts.Trigger()
+
+	return reservation, reservedProducts, nil
+}
+
+func (r *reservation) GetReservation(ctx context.Context, reservationID uint64) (*models.Reservation, error) {
+	ctx, span := tracing.Start(ctx, "reservation.GetReservation")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start GetReservation")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*6)
+	defer cancel()
+
+	reservation, err := r.repository.FindReservationViaID(ctx, reservationID)
+	if err != nil {
+		return nil, fmt.Errorf("FindReservationViaID failed: %w", err)
+	}
+
+	return reservation, nil
+}
+
+func (r *reservation) ReleaseReservation(ctx context.Context, reservationID uint64, owner string) (*models.Reservation, error) {
+	ctx, span := tracing.Start(ctx, "reservation.ReleaseReservation")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start ReleaseReservation")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*6)
+	defer cancel()
+
+	if _, err := r.ownReservation(ctx, reservationID, owner); err != nil {
+		return nil, err
+	}
+
+	if err := r.repository.SetReservationStatus(ctx, reservationID, models.ReservationReleased); err != nil {
+		return nil, fmt.Errorf("SetReservationStatus failed: %w", err)
 	}
+	r.alerts.Trigger()
 
-	if err := r.repository.ReserveProducts(ctx, *storage, filledProducts); err != nil {
-		return nil, fmt.Errorf("ReserveProducts failed: %w", err)
+	return r.GetReservation(ctx, reservationID)
+}
+
+func (r *reservation) FulfilReservation(ctx context.Context, reservationID uint64, owner string) (*models.Reservation, error) {
+	ctx, span := tracing.Start(ctx, "reservation.FulfilReservation")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FulfilReservation")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*6)
+	defer cancel()
+
+	if _, err := r.ownReservation(ctx, reservationID, owner); err != nil {
+		return nil, err
+	}
+
+	if err := r.repository.FulfilReservation(ctx, reservationID); err != nil {
+		return nil, fmt.Errorf("FulfilReservation failed: %w", err)
+	}
+
+	return r.GetReservation(ctx, reservationID)
+}
+
+func (r *reservation) ExtendReservation(ctx context.Context, reservationID uint64, owner string, ttl time.Duration) (*models.Reservation, error) {
+	ctx, span := tracing.Start(ctx, "reservation.ExtendReservation")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start ExtendReservation")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*6)
+	defer cancel()
+
+	if ttl <= 0 {
+		return nil, models.ErrTTLNotValid
+	}
+
+	if _, err := r.ownReservation(ctx, reservationID, owner); err != nil {
+		return nil, err
+	}
+
+	if err := r.repository.ExtendReservation(ctx, reservationID, ttl); err != nil {
+		return nil, fmt.Errorf("ExtendReservation failed: %w", err)
+	}
+
+	return r.GetReservation(ctx, reservationID)
+}
+
+// ownReservation проверяет, что резерв существует, активен и принадлежит владельцу.
+func (r *reservation) ownReservation(ctx context.Context, reservationID uint64, owner string) (*models.Reservation, error) {
+	reservation, err := r.GetReservation(ctx, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	if reservation.Owner != owner {
+		return nil, models.ErrReservationOwner
+	}
+	if reservation.Status != models.ReservationActive {
+		return nil, models.ErrReservationNotActive
 	}
 
-	return filledProducts, nil
+	return reservation, nil
 }
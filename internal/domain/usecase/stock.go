@@ -0,0 +1,104 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/metrics"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/tracing"
+)
+
+type StockRepo interface {
+	FindStocksViaStorageID(ctx context.Context, storageID uint) ([]models.Stock, error)
+	SetStocks(ctx context.Context, storageID uint, stocks []models.Stock) ([]models.Stock, error)
+	StreamStocksViaStorageID(ctx context.Context, storageID uint, fn func(models.Stock) error) error
+}
+
+type stock struct {
+	productService ProductService
+	repository     StockRepo
+	alerts         AlertTrigger
+}
+
+func NewStock(ps ProductService, r StockRepo, at AlertTrigger) *stock {
+	return &stock{
+		productService: ps,
+		repository:     r,
+		alerts:         at,
+	}
+}
+
+// FindStock возвращает остатки товаров на складе за вычетом активных резервов.
+func (s *stock) FindStock(ctx context.Context, storageID uint) ([]models.Stock, error) {
+	ctx, span := tracing.Start(ctx, "stock.FindStock")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start FindStock")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*6)
+	defer cancel()
+
+	stocks, err := s.repository.FindStocksViaStorageID(ctx, storageID)
+	if err != nil {
+		return nil, fmt.Errorf("FindStocksViaStorageID failed: %w", err)
+	}
+
+	return stocks, nil
+}
+
+// ExportStock передаёт остатки товаров склада в fn по одному.
+func (s *stock) ExportStock(ctx context.Context, storageID uint, fn func(models.Stock) error) error {
+	ctx, span := tracing.Start(ctx, "stock.ExportStock")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start ExportStock")
+
+	if err := s.repository.StreamStocksViaStorageID(ctx, storageID, fn); err != nil {
+		return fmt.Errorf("StreamStocksViaStorageID failed: %w", err)
+	}
+
+	return nil
+}
+
+// SetStock устанавливает количество товаров на складе по их кодам.
+// Возвращает список неизвестных кодов, если хотя бы один товар не найден.
+func (s *stock) SetStock(ctx context.Context, storageID uint, stocks []models.Stock) ([]models.Stock, []string, error) {
+	ctx, span := tracing.Start(ctx, "stock.SetStock")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start SetStock")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*6)
+	defer cancel()
+	metrics.ObserveBatch("stock", len(stocks))
+
+	products := make([]models.Item, 0, len(stocks))
+	for _, stock := range stocks {
+		products = append(products, models.Item{Product: models.Product{Code: stock.Code}})
+	}
+	filledProducts, err := s.productService.GetProductsInfo(ctx, products)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetProductsInfo failed: %w", err)
+	}
+
+	notFound := make([]string, 0)
+	for i, product := range filledProducts {
+		if product.ID == 0 {
+			notFound = append(notFound, product.Code)
+			continue
+		}
+		stocks[i].ProductID = product.ID
+	}
+	if len(notFound) > 0 {
+		return nil, notFound, models.ErrProductsNotFound
+	}
+
+	updated, err := s.repository.SetStocks(ctx, storageID, stocks)
+	if err != nil {
+		return nil, nil, fmt.Errorf("SetStocks failed: %w", err)
+	}
+	s.alerts.Trigger()
+
+	return updated, nil, nil
+}
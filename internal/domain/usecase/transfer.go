@@ -0,0 +1,140 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/tracing"
+)
+
+type TransferRepo interface {
+	CreateTransfer(ctx context.Context, transfer models.Transfer) (*models.Transfer, error)
+	FindTransferViaID(ctx context.Context, transferID uint64) (*models.Transfer, error)
+	CompleteTransfer(ctx context.Context, transferID uint64) (*models.Transfer, error)
+	CancelTransfer(ctx context.Context, transferID uint64) (*models.Transfer, error)
+}
+
+type transfer struct {
+	productService ProductService
+	repository     TransferRepo
+	alerts         AlertTrigger
+}
+
+func NewTransfer(ps ProductService, r TransferRepo, at AlertTrigger) *transfer {
+	return &transfer{
+		productService: ps,
+		repository:     r,
+		alerts:         at,
+	}
+}
+
+// CreateTransfer отправляет товары со склада-источника на склад назначения.
+// Возвращает список неизвестных кодов, если хотя бы один товар не найден.
+func (t *transfer) CreateTransfer(ctx context.Context, request models.Transfer) (*models.Transfer, []string, error) {
+	ctx, span := tracing.Start(ctx, "transfer.CreateTransfer")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CreateTransfer")
+	ctx, cancel := context.WithTimeout(ctx, time.Second*6)
+	defer cancel()
+
+	if err := request.Validate(); err != nil {
+		return nil, nil, err
+	}
+	policy, err := models.ParseReservationPolicy(string(request.Reservations))
+	if err != nil {
+		return nil, nil, err
+	}
+	request.Reservations = policy
+
+	products := make([]models.Item, 0, len(request.Lines))
+	for _, line := range request.Lines {
+		products = append(products, models.Item{Product: models.Product{Code: line.Code}})
+	}
+	filledProducts, err := t.productService.GetProductsInfo(ctx, products)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetProductsInfo failed: %w", err)
+	}
+
+	// повторяющиеся товары объединяются в одну строку
+	notFound := make([]string, 0)
+	lines := make([]models.TransferLine, 0, len(request.Lines))
+	index := make(map[uint]int, len(request.Lines))
+	for i, product := range filledProducts {
+		if product.ID == 0 {
+			notFound = append(notFound, product.Code)
+			continue
+		}
+		if j, ok := index[product.ID]; ok {
+			lines[j].Quantity += request.Lines[i].Quantity
+			continue
+		}
+		index[product.ID] = len(lines)
+		lines = append(lines, models.TransferLine{
+			ProductID: product.ID,
+			Code:      product.Code,
+			Quantity:  request.Lines[i].Quantity,
+		})
+	}
+	if len(notFound) > 0 {
+		return nil, notFound, models.ErrProductsNotFound
+	}
+	request.Lines = lines
+
+	created, err := t.repository.CreateTransfer(ctx, request)
+	if err != nil {
+		return nil, nil, fmt.Errorf("CreateTransfer failed: %w", err)
+	}
+	t.alerts.Trigger()
+
+	return created, nil, nil
+}
+
+func (t *transfer) GetTransfer(ctx context.Context, transferID uint64) (*models.Transfer, error) {
+	ctx, span := tracing.Start(ctx, "transfer.GetTransfer")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start GetTransfer")
+
+	found, err := t.repository.FindTransferViaID(ctx, transferID)
+	if err != nil {
+		return nil, fmt.Errorf("FindTransferViaID failed: %w", err)
+	}
+
+	return found, nil
+}
+
+// CompleteTransfer зачисляет товары в пути на склад назначения.
+func (t *transfer) CompleteTransfer(ctx context.Context, transferID uint64) (*models.Transfer, error) {
+	ctx, span := tracing.Start(ctx, "transfer.CompleteTransfer")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CompleteTransfer")
+
+	completed, err := t.repository.CompleteTransfer(ctx, transferID)
+	if err != nil {
+		return nil, fmt.Errorf("CompleteTransfer failed: %w", err)
+	}
+	t.alerts.Trigger()
+
+	return completed, nil
+}
+
+// CancelTransfer возвращает товары в пути на склад-источник.
+func (t *transfer) CancelTransfer(ctx context.Context, transferID uint64) (*models.Transfer, error) {
+	ctx, span := tracing.Start(ctx, "transfer.CancelTransfer")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CancelTransfer")
+
+	cancelled, err := t.repository.CancelTransfer(ctx, transferID)
+	if err != nil {
+		return nil, fmt.Errorf("CancelTransfer failed: %w", err)
+	}
+	t.alerts.Trigger()
+
+	return cancelled, nil
+}
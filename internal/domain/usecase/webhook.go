@@ -0,0 +1,147 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Shurubtsov/lamoda-test-task/internal/domain/models"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/logging"
+	"github.com/Shurubtsov/lamoda-test-task/pkg/tracing"
+)
+
+const (
+	defaultDeliveriesLimit = 100
+	maxDeliveriesLimit     = 1000
+)
+
+type WebhookRepo interface {
+	CreateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error)
+	FindWebhooks(ctx context.Context) ([]models.Webhook, error)
+	FindWebhookViaID(ctx context.Context, webhookID uint) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID uint) error
+	FindDeliveries(ctx context.Context, filter models.DeliveryFilter) ([]models.WebhookDelivery, error)
+	RetryDelivery(ctx context.Context, deliveryID uint64) (*models.WebhookDelivery, error)
+}
+
+type webhook struct {
+	repository WebhookRepo
+}
+
+func NewWebhook(r WebhookRepo) *webhook {
+	return &webhook{repository: r}
+}
+
+// CreateWebhook подписывает URL на события, повторяющиеся типы событий отбрасываются.
+func (w *webhook) CreateWebhook(ctx context.Context, request models.Webhook) (*models.Webhook, error) {
+	ctx, span := tracing.Start(ctx, "webhook.CreateWebhook")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start CreateWebhook")
+
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	events := make([]models.EventType, 0, len(request.Events))
+	seen := make(map[models.EventType]bool, len(request.Events))
+	for _, eventType := range request.Events {
+		if !seen[eventType] {
+			seen[eventType] = true
+			events = append(events, eventType)
+		}
+	}
+	request.Events = events
+
+	created, err := w.repository.CreateWebhook(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("CreateWebhook failed: %w", err)
+	}
+
+	return created, nil
+}
+
+func (w *webhook) GetWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	ctx, span := tracing.Start(ctx, "webhook.GetWebhooks")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start GetWebhooks")
+
+	webhooks, err := w.repository.FindWebhooks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("FindWebhooks failed: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+func (w *webhook) GetWebhook(ctx context.Context, webhookID uint) (*models.Webhook, error) {
+	ctx, span := tracing.Start(ctx, "webhook.GetWebhook")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start GetWebhook")
+
+	found, err := w.repository.FindWebhookViaID(ctx, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("FindWebhookViaID failed: %w", err)
+	}
+
+	return found, nil
+}
+
+func (w *webhook) DeleteWebhook(ctx context.Context, webhookID uint) error {
+	ctx, span := tracing.Start(ctx, "webhook.DeleteWebhook")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start DeleteWebhook")
+
+	if err := w.repository.DeleteWebhook(ctx, webhookID); err != nil {
+		return fmt.Errorf("DeleteWebhook failed: %w", err)
+	}
+
+	return nil
+}
+
+// GetDeliveries возвращает страницу журнала доставок, нулевой limit заменяется значением по умолчанию.
+// Журнал конкретной подписки запрашивается с её WebhookID, несуществующая подписка даёт ErrWebhookNotFound.
+func (w *webhook) GetDeliveries(ctx context.Context, filter models.DeliveryFilter) ([]models.WebhookDelivery, error) {
+	ctx, span := tracing.Start(ctx, "webhook.GetDeliveries")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start GetDeliveries")
+
+	if err := filter.Status.Validate(); err != nil {
+		return nil, err
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultDeliveriesLimit
+	}
+	if filter.Limit < 0 || filter.Limit > maxDeliveriesLimit || filter.Offset < 0 {
+		return nil, models.ErrPageNotValid
+	}
+	if filter.WebhookID != 0 {
+		if _, err := w.GetWebhook(ctx, filter.WebhookID); err != nil {
+			return nil, err
+		}
+	}
+
+	deliveries, err := w.repository.FindDeliveries(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("FindDeliveries failed: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// RetryDelivery возвращает недоставленное событие в очередь доставки.
+func (w *webhook) RetryDelivery(ctx context.Context, deliveryID uint64) (*models.WebhookDelivery, error) {
+	ctx, span := tracing.Start(ctx, "webhook.RetryDelivery")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Trace().Msg("start RetryDelivery")
+
+	delivery, err := w.repository.RetryDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("RetryDelivery failed: %w", err)
+	}
+
+	return delivery, nil
+}
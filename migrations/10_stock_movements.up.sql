@@ -0,0 +1,40 @@
+-- журнал движения остатков, on_hand_change и reserved_change - изменения количества на складе
+-- и в активных резервах, сумма изменений до момента времени даёт состояние остатков на этот момент
+CREATE TABLE IF NOT EXISTS stock_movements (
+	movement_id BIGSERIAL PRIMARY KEY,
+	movement_type VARCHAR (20) NOT NULL
+		CHECK (movement_type IN ('receipt', 'reservation', 'release', 'shipment', 'adjustment', 'transfer')),
+	storage_id INT NOT NULL,
+	product_id INT NOT NULL,
+	on_hand_change INT NOT NULL DEFAULT 0,
+	reserved_change INT NOT NULL DEFAULT 0,
+	reservation_id BIGINT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	FOREIGN KEY (storage_id)
+		REFERENCES storages (storage_id),
+	FOREIGN KEY (product_id)
+		REFERENCES products (product_id),
+	FOREIGN KEY (reservation_id)
+		REFERENCES reservations (reservation_id)
+);
+
+CREATE INDEX IF NOT EXISTS stock_movements_product_idx ON stock_movements (product_id, created_at);
+
+CREATE OR REPLACE FUNCTION stock_movements_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_movements_append_only
+	BEFORE UPDATE OR DELETE ON stock_movements
+	FOR EACH STATEMENT EXECUTE FUNCTION stock_movements_append_only();
+
+-- начальные остатки и активные резервы на момент появления журнала
+INSERT INTO stock_movements (movement_type, storage_id, product_id, on_hand_change)
+SELECT 'adjustment', storage_id, product_id, quantity FROM stocks WHERE quantity > 0;
+
+INSERT INTO stock_movements (movement_type, storage_id, product_id, reserved_change, reservation_id)
+SELECT 'reservation', i.storage_id, i.product_id, i.quantity, i.reservation_id
+FROM reservation_items i JOIN reservations r ON r.reservation_id = i.reservation_id
+WHERE r.reservation_status = 'active';
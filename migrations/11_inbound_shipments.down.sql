@@ -0,0 +1,3 @@
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS inbound_id;
+DROP TABLE IF EXISTS inbound_lines;
+DROP TABLE IF EXISTS inbound_shipments;
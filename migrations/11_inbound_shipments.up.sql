@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS inbound_shipments (
+	inbound_id BIGSERIAL PRIMARY KEY,
+	storage_id INT NOT NULL,
+	inbound_reference VARCHAR (100) NOT NULL DEFAULT '',
+	inbound_status VARCHAR (20) NOT NULL DEFAULT 'expected'
+		CHECK (inbound_status IN ('expected', 'received')),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	received_at TIMESTAMPTZ,
+	FOREIGN KEY (storage_id)
+		REFERENCES storages (storage_id)
+);
+
+-- expected_quantity = 0 у строк, которые не ожидались, но пришли при приёмке
+CREATE TABLE IF NOT EXISTS inbound_lines (
+	inbound_id BIGINT NOT NULL,
+	product_id INT NOT NULL,
+	expected_quantity INT NOT NULL CHECK (expected_quantity >= 0),
+	received_quantity INT CHECK (received_quantity >= 0),
+	PRIMARY KEY (inbound_id, product_id),
+	FOREIGN KEY (inbound_id)
+		REFERENCES inbound_shipments (inbound_id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id)
+		REFERENCES products (product_id)
+);
+
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS inbound_id BIGINT REFERENCES inbound_shipments (inbound_id);
@@ -0,0 +1,4 @@
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS transfer_id;
+DROP TABLE IF EXISTS transfer_reservations;
+DROP TABLE IF EXISTS transfer_lines;
+DROP TABLE IF EXISTS transfers;
@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS transfers (
+	transfer_id BIGSERIAL PRIMARY KEY,
+	source_storage_id INT NOT NULL,
+	destination_storage_id INT NOT NULL,
+	reservation_policy VARCHAR (20) NOT NULL DEFAULT 'block'
+		CHECK (reservation_policy IN ('block', 'migrate')),
+	transfer_status VARCHAR (20) NOT NULL DEFAULT 'in_transit'
+		CHECK (transfer_status IN ('in_transit', 'completed', 'cancelled')),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	CHECK (source_storage_id <> destination_storage_id),
+	FOREIGN KEY (source_storage_id)
+		REFERENCES storages (storage_id),
+	FOREIGN KEY (destination_storage_id)
+		REFERENCES storages (storage_id)
+);
+
+CREATE TABLE IF NOT EXISTS transfer_lines (
+	transfer_id BIGINT NOT NULL,
+	product_id INT NOT NULL,
+	quantity INT NOT NULL CHECK (quantity > 0),
+	PRIMARY KEY (transfer_id, product_id),
+	FOREIGN KEY (transfer_id)
+		REFERENCES transfers (transfer_id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id)
+		REFERENCES products (product_id)
+);
+
+-- позиции резервов, перенесённые на склад назначения вместе с товаром, при отмене перемещения возвращаются обратно
+CREATE TABLE IF NOT EXISTS transfer_reservations (
+	transfer_id BIGINT NOT NULL,
+	reservation_id BIGINT NOT NULL,
+	product_id INT NOT NULL,
+	quantity INT NOT NULL CHECK (quantity > 0),
+	PRIMARY KEY (transfer_id, reservation_id, product_id),
+	FOREIGN KEY (transfer_id)
+		REFERENCES transfers (transfer_id) ON DELETE CASCADE,
+	FOREIGN KEY (reservation_id)
+		REFERENCES reservations (reservation_id)
+);
+
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS transfer_id BIGINT REFERENCES transfers (transfer_id);
@@ -0,0 +1,3 @@
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS count_id;
+DROP TABLE IF EXISTS inventory_count_lines;
+DROP TABLE IF EXISTS inventory_counts;
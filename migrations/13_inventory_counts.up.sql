@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS inventory_counts (
+	count_id BIGSERIAL PRIMARY KEY,
+	storage_id INT NOT NULL,
+	count_status VARCHAR (20) NOT NULL DEFAULT 'open'
+		CHECK (count_status IN ('open', 'committed', 'cancelled')),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	committed_at TIMESTAMPTZ,
+	FOREIGN KEY (storage_id)
+		REFERENCES storages (storage_id)
+);
+
+-- system_quantity - остаток по данным системы на момент применения пересчёта
+CREATE TABLE IF NOT EXISTS inventory_count_lines (
+	count_id BIGINT NOT NULL,
+	product_id INT NOT NULL,
+	counted_quantity INT NOT NULL CHECK (counted_quantity >= 0),
+	system_quantity INT,
+	counted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (count_id, product_id),
+	FOREIGN KEY (count_id)
+		REFERENCES inventory_counts (count_id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id)
+		REFERENCES products (product_id)
+);
+
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS count_id BIGINT REFERENCES inventory_counts (count_id);
@@ -0,0 +1,38 @@
+-- порог без склада действует на всех складах, где есть остаток товара, порог склада его переопределяет
+CREATE TABLE IF NOT EXISTS stock_thresholds (
+	threshold_id SERIAL PRIMARY KEY,
+	product_id INT NOT NULL,
+	storage_id INT,
+	threshold INT NOT NULL CHECK (threshold > 0),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	FOREIGN KEY (product_id)
+		REFERENCES products (product_id) ON DELETE CASCADE,
+	FOREIGN KEY (storage_id)
+		REFERENCES storages (storage_id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS stock_thresholds_product_idx
+	ON stock_thresholds (product_id) WHERE storage_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS stock_thresholds_product_storage_idx
+	ON stock_thresholds (product_id, storage_id) WHERE storage_id IS NOT NULL;
+
+-- available и threshold фиксируются на момент появления предупреждения
+CREATE TABLE IF NOT EXISTS stock_alerts (
+	alert_id BIGSERIAL PRIMARY KEY,
+	storage_id INT NOT NULL,
+	product_id INT NOT NULL,
+	threshold INT NOT NULL,
+	available INT NOT NULL,
+	alert_status VARCHAR (10) NOT NULL DEFAULT 'open'
+		CHECK (alert_status IN ('open', 'resolved')),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	resolved_at TIMESTAMPTZ,
+	FOREIGN KEY (storage_id)
+		REFERENCES storages (storage_id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id)
+		REFERENCES products (product_id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS stock_alerts_open_idx
+	ON stock_alerts (storage_id, product_id) WHERE alert_status = 'open';
+CREATE INDEX IF NOT EXISTS stock_alerts_created_idx ON stock_alerts (created_at);
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+	webhook_id SERIAL PRIMARY KEY,
+	webhook_url TEXT NOT NULL,
+	event_types TEXT[] NOT NULL,
+	webhook_secret TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- доставка события подписчику, dead - попытки доставки исчерпаны
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	delivery_id BIGSERIAL PRIMARY KEY,
+	webhook_id INT NOT NULL,
+	event_type VARCHAR (50) NOT NULL,
+	payload JSONB NOT NULL,
+	delivery_status VARCHAR (10) NOT NULL DEFAULT 'pending'
+		CHECK (delivery_status IN ('pending', 'delivered', 'dead')),
+	attempts INT NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_status_code INT,
+	last_error TEXT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	delivered_at TIMESTAMPTZ,
+	FOREIGN KEY (webhook_id)
+		REFERENCES webhooks (webhook_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx
+	ON webhook_deliveries (next_attempt_at) WHERE delivery_status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, delivery_id);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS stock_movements_outbox ON stock_movements;
+DROP FUNCTION IF EXISTS stock_movements_outbox();
+DROP TABLE IF EXISTS outbox_events;
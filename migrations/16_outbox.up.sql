@@ -0,0 +1,41 @@
+-- события для шины сообщений, event_key - ключ упорядочивания (идентификатор товара)
+CREATE TABLE IF NOT EXISTS outbox_events (
+	event_id BIGSERIAL PRIMARY KEY,
+	event_type VARCHAR (50) NOT NULL,
+	event_key VARCHAR (64) NOT NULL,
+	payload JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_events_pending_idx ON outbox_events (event_id) WHERE sent_at IS NULL;
+
+-- каждая запись журнала движения порождает событие в той же транзакции, поэтому резервирование,
+-- освобождение резервов и любое изменение остатков попадает в outbox без отдельной записи в коде
+CREATE OR REPLACE FUNCTION stock_movements_outbox() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO outbox_events (event_type, event_key, payload)
+	SELECT 'stock.' || m.movement_type, m.product_id::text, jsonb_build_object(
+		'movement_id', m.movement_id,
+		'type', m.movement_type,
+		'storage_id', m.storage_id,
+		'product_id', m.product_id,
+		'code', p.product_code,
+		'on_hand_change', m.on_hand_change,
+		'reserved_change', m.reserved_change,
+		'reservation_id', m.reservation_id,
+		'inbound_id', m.inbound_id,
+		'transfer_id', m.transfer_id,
+		'count_id', m.count_id,
+		'created_at', m.created_at
+	)
+	FROM inserted m JOIN products p ON p.product_id = m.product_id
+	ORDER BY m.movement_id;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_movements_outbox
+	AFTER INSERT ON stock_movements
+	REFERENCING NEW TABLE AS inserted
+	FOR EACH STATEMENT EXECUTE FUNCTION stock_movements_outbox();
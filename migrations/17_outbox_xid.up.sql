@@ -0,0 +1,4 @@
+-- идентификатор транзакции, записавшей событие: ретранслятор публикует только события транзакций,
+-- завершившихся раньше самой старой из выполняющихся, иначе событие с меньшим event_id
+-- могло бы зафиксироваться уже после публикации более позднего события того же товара
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS xact_id xid8 NOT NULL DEFAULT pg_current_xact_id();
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS outbox_events_undispatched_idx;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS dispatched_at;
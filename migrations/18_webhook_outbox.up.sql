@@ -0,0 +1,6 @@
+-- события для подписчиков строятся по событиям outbox, dispatched_at - время создания доставок по событию,
+-- события, записанные до появления колонки, уже разосланы через прежнюю очередь
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS dispatched_at TIMESTAMPTZ;
+UPDATE outbox_events SET dispatched_at = now();
+
+CREATE INDEX IF NOT EXISTS outbox_events_undispatched_idx ON outbox_events (event_id) WHERE dispatched_at IS NULL;
@@ -0,0 +1,9 @@
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS content_type VARCHAR (100);
+
+UPDATE idempotency_keys SET content_type = left(response_headers -> 'Content-Type' ->> 0, 100)
+WHERE response_headers ? 'Content-Type';
+
+ALTER TABLE idempotency_keys
+	DROP COLUMN IF EXISTS lease_token,
+	DROP COLUMN IF EXISTS locked_until,
+	DROP COLUMN IF EXISTS response_headers;
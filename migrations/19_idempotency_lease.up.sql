@@ -0,0 +1,14 @@
+-- lease_token и locked_until - захват ключа запросом: если запрос не завершился до locked_until
+-- (например, процесс остановился), ключ перезанимает повтор с тем же запросом.
+-- response_headers - заголовки сохранённого ответа, заменяют content_type
+ALTER TABLE idempotency_keys
+	ADD COLUMN IF NOT EXISTS lease_token UUID,
+	ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ,
+	ADD COLUMN IF NOT EXISTS response_headers JSONB;
+
+UPDATE idempotency_keys SET response_headers = jsonb_build_object('Content-Type', jsonb_build_array(content_type))
+WHERE content_type IS NOT NULL;
+UPDATE idempotency_keys SET lease_token = gen_random_uuid(), locked_until = created_at + interval '1 minute'
+WHERE completed_at IS NULL;
+
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS content_type;
@@ -0,0 +1,9 @@
+-- слитые товары восстанавливаются с кодом <код>D<id> без остатков, резервов и истории:
+-- они остаются на товаре, в который слиты
+INSERT INTO products (product_id, product_name, product_code, product_size)
+	SELECT product_id, product_name, renamed_code, product_size FROM product_code_merges
+ON CONFLICT DO NOTHING;
+INSERT INTO product_code_renames (product_id, old_code, new_code)
+	SELECT product_id, product_code, renamed_code FROM product_code_merges
+ON CONFLICT DO NOTHING;
+DROP TABLE IF EXISTS product_code_merges;
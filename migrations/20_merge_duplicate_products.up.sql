@@ -0,0 +1,93 @@
+-- товары, переименованные миграцией 9 в <код>D<id>, сливаются с товаром, который носит исходный код:
+-- остатки, резервы и строки документов переносятся на него, журнал движения и предупреждения
+-- перепривязываются, слияния сохраняются для сверки. Товар, код которого с тех пор изменили,
+-- или исходный код которого больше никому не принадлежит, не сливается.
+CREATE TABLE IF NOT EXISTS product_code_merges (
+	product_id INT PRIMARY KEY,
+	merged_into INT NOT NULL,
+	product_code VARCHAR (32) NOT NULL,
+	renamed_code VARCHAR (32) NOT NULL,
+	product_name VARCHAR (50),
+	product_size SMALLINT,
+	merged_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	FOREIGN KEY (merged_into)
+		REFERENCES products (product_id)
+);
+
+INSERT INTO product_code_merges (product_id, merged_into, product_code, renamed_code, product_name, product_size)
+SELECT p.product_id, k.product_id, r.old_code, r.new_code, p.product_name, p.product_size
+FROM product_code_renames r
+JOIN products p ON p.product_id = r.product_id AND p.product_code = r.new_code
+JOIN products k ON k.product_code = r.old_code;
+
+INSERT INTO stocks (storage_id, product_id, quantity)
+	SELECT s.storage_id, m.merged_into, SUM(s.quantity)
+	FROM stocks s JOIN product_code_merges m ON m.product_id = s.product_id
+	GROUP BY s.storage_id, m.merged_into
+ON CONFLICT (storage_id, product_id) DO UPDATE SET quantity = stocks.quantity + EXCLUDED.quantity;
+DELETE FROM stocks s USING product_code_merges m WHERE s.product_id = m.product_id;
+
+INSERT INTO reservation_items (reservation_id, storage_id, product_id, quantity)
+	SELECT i.reservation_id, i.storage_id, m.merged_into, SUM(i.quantity)
+	FROM reservation_items i JOIN product_code_merges m ON m.product_id = i.product_id
+	GROUP BY i.reservation_id, i.storage_id, m.merged_into
+ON CONFLICT (reservation_id, storage_id, product_id) DO UPDATE SET quantity = reservation_items.quantity + EXCLUDED.quantity;
+DELETE FROM reservation_items i USING product_code_merges m WHERE i.product_id = m.product_id;
+
+INSERT INTO inbound_lines (inbound_id, product_id, expected_quantity, received_quantity)
+	SELECT l.inbound_id, m.merged_into, SUM(l.expected_quantity), SUM(l.received_quantity)
+	FROM inbound_lines l JOIN product_code_merges m ON m.product_id = l.product_id
+	GROUP BY l.inbound_id, m.merged_into
+ON CONFLICT (inbound_id, product_id) DO UPDATE SET
+	expected_quantity = inbound_lines.expected_quantity + EXCLUDED.expected_quantity,
+	received_quantity = CASE WHEN inbound_lines.received_quantity IS NULL AND EXCLUDED.received_quantity IS NULL THEN NULL
+		ELSE COALESCE(inbound_lines.received_quantity, 0) + COALESCE(EXCLUDED.received_quantity, 0) END;
+DELETE FROM inbound_lines l USING product_code_merges m WHERE l.product_id = m.product_id;
+
+INSERT INTO transfer_lines (transfer_id, product_id, quantity)
+	SELECT l.transfer_id, m.merged_into, SUM(l.quantity)
+	FROM transfer_lines l JOIN product_code_merges m ON m.product_id = l.product_id
+	GROUP BY l.transfer_id, m.merged_into
+ON CONFLICT (transfer_id, product_id) DO UPDATE SET quantity = transfer_lines.quantity + EXCLUDED.quantity;
+DELETE FROM transfer_lines l USING product_code_merges m WHERE l.product_id = m.product_id;
+
+INSERT INTO transfer_reservations (transfer_id, reservation_id, product_id, quantity)
+	SELECT t.transfer_id, t.reservation_id, m.merged_into, SUM(t.quantity)
+	FROM transfer_reservations t JOIN product_code_merges m ON m.product_id = t.product_id
+	GROUP BY t.transfer_id, t.reservation_id, m.merged_into
+ON CONFLICT (transfer_id, reservation_id, product_id) DO UPDATE SET quantity = transfer_reservations.quantity + EXCLUDED.quantity;
+DELETE FROM transfer_reservations t USING product_code_merges m WHERE t.product_id = m.product_id;
+
+INSERT INTO inventory_count_lines (count_id, product_id, counted_quantity, system_quantity, counted_at)
+	SELECT l.count_id, m.merged_into, SUM(l.counted_quantity), SUM(l.system_quantity), MAX(l.counted_at)
+	FROM inventory_count_lines l JOIN product_code_merges m ON m.product_id = l.product_id
+	GROUP BY l.count_id, m.merged_into
+ON CONFLICT (count_id, product_id) DO UPDATE SET
+	counted_quantity = inventory_count_lines.counted_quantity + EXCLUDED.counted_quantity,
+	system_quantity = CASE WHEN inventory_count_lines.system_quantity IS NULL AND EXCLUDED.system_quantity IS NULL THEN NULL
+		ELSE COALESCE(inventory_count_lines.system_quantity, 0) + COALESCE(EXCLUDED.system_quantity, 0) END,
+	counted_at = GREATEST(inventory_count_lines.counted_at, EXCLUDED.counted_at);
+DELETE FROM inventory_count_lines l USING product_code_merges m WHERE l.product_id = m.product_id;
+
+-- пороги сохранённого товара важнее порогов дубликата
+UPDATE stock_thresholds t SET product_id = m.merged_into
+FROM product_code_merges m
+WHERE t.product_id = m.product_id AND NOT EXISTS (
+	SELECT 1 FROM stock_thresholds k
+	WHERE k.product_id = m.merged_into AND k.storage_id IS NOT DISTINCT FROM t.storage_id
+);
+DELETE FROM stock_thresholds t USING product_code_merges m WHERE t.product_id = m.product_id;
+
+-- открытые предупреждения дубликата закрываются, фоновая проверка откроет их заново по общему остатку
+UPDATE stock_alerts a SET alert_status = 'resolved', resolved_at = now()
+FROM product_code_merges m WHERE a.product_id = m.product_id AND a.alert_status = 'open';
+UPDATE stock_alerts a SET product_id = m.merged_into
+FROM product_code_merges m WHERE a.product_id = m.product_id;
+
+-- журнал движения перепривязывается, чтобы сумма изменений по-прежнему давала остатки товара
+ALTER TABLE stock_movements DISABLE TRIGGER stock_movements_append_only;
+UPDATE stock_movements s SET product_id = m.merged_into
+FROM product_code_merges m WHERE s.product_id = m.product_id;
+ALTER TABLE stock_movements ENABLE TRIGGER stock_movements_append_only;
+
+DELETE FROM products p USING product_code_merges m WHERE p.product_id = m.product_id;
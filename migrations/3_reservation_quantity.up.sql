@@ -0,0 +1 @@
+ALTER TABLE reservation ADD COLUMN IF NOT EXISTS quantity INT NOT NULL DEFAULT 1 CHECK (quantity > 0);
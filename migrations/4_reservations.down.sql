@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS reservation (
+	storage_id INT NOT NULL,
+	product_id INT NOT NULL,
+	quantity INT NOT NULL DEFAULT 1 CHECK (quantity > 0),
+	PRIMARY KEY (storage_id, product_id),
+	FOREIGN KEY (storage_id)
+		REFERENCES storages (storage_id),
+	FOREIGN KEY (product_id)
+		REFERENCES products (product_id)
+);
+
+INSERT INTO reservation (storage_id, product_id, quantity)
+	SELECT i.storage_id, i.product_id, SUM(i.quantity)
+	FROM reservation_items i
+	JOIN reservations r ON r.reservation_id = i.reservation_id
+	WHERE r.reservation_status = 'active'
+	GROUP BY i.storage_id, i.product_id;
+
+DROP TABLE IF EXISTS reservation_items;
+DROP TABLE IF EXISTS reservations;
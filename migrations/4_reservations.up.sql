@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS reservations (
+	reservation_id BIGSERIAL PRIMARY KEY,
+	reservation_owner VARCHAR (100) NOT NULL,
+	reservation_status VARCHAR (10) NOT NULL DEFAULT 'active'
+		CHECK (reservation_status IN ('active', 'released', 'fulfilled', 'expired')),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE reservation RENAME TO reservation_items;
+ALTER TABLE reservation_items ADD COLUMN reservation_id BIGINT;
+
+-- резервы созданные до появления идентификаторов переносятся в один общий резерв
+INSERT INTO reservations (reservation_owner)
+	SELECT 'legacy' WHERE EXISTS (SELECT 1 FROM reservation_items);
+UPDATE reservation_items SET reservation_id = (SELECT MIN(reservation_id) FROM reservations);
+
+ALTER TABLE reservation_items ALTER COLUMN reservation_id SET NOT NULL;
+ALTER TABLE reservation_items DROP CONSTRAINT reservation_pkey;
+ALTER TABLE reservation_items ADD PRIMARY KEY (reservation_id, storage_id, product_id);
+ALTER TABLE reservation_items ADD FOREIGN KEY (reservation_id)
+	REFERENCES reservations (reservation_id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS reservation_items_product_idx ON reservation_items (product_id);
+CREATE INDEX IF NOT EXISTS reservations_owner_status_idx ON reservations (reservation_owner, reservation_status);
@@ -0,0 +1,4 @@
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS reservations_expires_at_idx ON reservations (expires_at)
+	WHERE reservation_status = 'active' AND expires_at IS NOT NULL;
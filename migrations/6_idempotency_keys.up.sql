@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	idempotency_key VARCHAR (255) PRIMARY KEY,
+	request_hash CHAR (64) NOT NULL,
+	status_code SMALLINT,
+	content_type VARCHAR (100),
+	response_body BYTEA,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at_idx ON idempotency_keys (created_at);
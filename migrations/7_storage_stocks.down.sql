@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS stocks;
+ALTER TABLE storages DROP COLUMN IF EXISTS storage_priority;
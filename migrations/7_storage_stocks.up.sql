@@ -0,0 +1,26 @@
+ALTER TABLE storages ADD COLUMN IF NOT EXISTS storage_priority INT NOT NULL DEFAULT 0;
+-- сохраняем прежний порядок выбора складов
+UPDATE storages SET storage_priority = storage_id;
+
+CREATE TABLE IF NOT EXISTS stocks (
+	storage_id INT NOT NULL,
+	product_id INT NOT NULL,
+	quantity INT NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+	PRIMARY KEY (storage_id, product_id),
+	FOREIGN KEY (storage_id)
+		REFERENCES storages (storage_id),
+	FOREIGN KEY (product_id)
+		REFERENCES products (product_id)
+);
+
+CREATE INDEX IF NOT EXISTS stocks_product_idx ON stocks (product_id);
+
+-- остаток товара переносится на склад, где уже лежат его резервы, иначе на первый доступный склад
+INSERT INTO stocks (storage_id, product_id, quantity)
+	SELECT storage_id, product_id, quantity FROM (
+		SELECT COALESCE(
+			(SELECT MIN(i.storage_id) FROM reservation_items i WHERE i.product_id = p.product_id),
+			(SELECT storage_id FROM storages WHERE storage_aviable ORDER BY storage_priority, storage_id LIMIT 1)
+		) AS storage_id, p.product_id, COALESCE(p.product_count, 0) AS quantity
+		FROM products p
+	) s WHERE storage_id IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS product_count SMALLINT CHECK (product_count >= 0);
+UPDATE products p SET product_count = COALESCE((SELECT SUM(s.quantity) FROM stocks s WHERE s.product_id = p.product_id), 0);
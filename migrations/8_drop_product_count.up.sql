@@ -0,0 +1,2 @@
+-- остатки товаров хранятся только в разрезе складов в таблице stocks
+ALTER TABLE products DROP COLUMN IF EXISTS product_count;
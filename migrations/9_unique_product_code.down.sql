@@ -0,0 +1,5 @@
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_product_code_key;
+UPDATE products p SET product_code = r.old_code
+FROM product_code_renames r WHERE r.product_id = p.product_id;
+DROP TABLE IF EXISTS product_code_renames;
+ALTER TABLE products ALTER COLUMN product_code TYPE VARCHAR (10);
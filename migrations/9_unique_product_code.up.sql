@@ -0,0 +1,26 @@
+-- код товара становится уникальным, дубликаты переименовываются в <код>D<id>,
+-- первым по product_id остаётся исходный код, переименования сохраняются для сверки
+ALTER TABLE products ALTER COLUMN product_code TYPE VARCHAR (32);
+
+CREATE TABLE IF NOT EXISTS product_code_renames (
+	product_id INT PRIMARY KEY,
+	old_code VARCHAR (32) NOT NULL,
+	new_code VARCHAR (32) NOT NULL,
+	renamed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	FOREIGN KEY (product_id)
+		REFERENCES products (product_id) ON DELETE CASCADE
+);
+
+WITH duplicates AS (
+	SELECT product_id, product_code,
+		ROW_NUMBER() OVER (PARTITION BY product_code ORDER BY product_id) AS n
+	FROM products
+)
+INSERT INTO product_code_renames (product_id, old_code, new_code)
+SELECT product_id, product_code, product_code || 'D' || product_id
+FROM duplicates WHERE n > 1;
+
+UPDATE products p SET product_code = r.new_code
+FROM product_code_renames r WHERE r.product_id = p.product_id;
+
+ALTER TABLE products ADD CONSTRAINT products_product_code_key UNIQUE (product_code);
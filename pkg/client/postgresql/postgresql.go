@@ -20,6 +20,8 @@ type Client interface {
 	Begin(ctx context.Context) (pgx.Tx, error)
 	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
 	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	Ping(ctx context.Context) error
+	Stat() *pgxpool.Stat
 }
 
 // NewClient создаёт новый клиент пула соединений pgxpool от PGX драйвера для PostgreSQL.
@@ -34,7 +36,14 @@ func NewClient(ctx context.Context, maxAttemts int) (pool *pgxpool.Pool, err err
 		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 		defer cancel()
 
-		pool, err = pgxpool.New(ctx, dsn)
+		poolConfig, err := pgxpool.ParseConfig(dsn)
+		if err != nil {
+			return err
+		}
+		// запросы к бд попадают в трассировку дочерними спанами запроса сервиса
+		poolConfig.ConnConfig.Tracer = &tracer{database: cfg.Storage.Database}
+
+		pool, err = pgxpool.NewWithConfig(ctx, poolConfig)
 		if err != nil {
 			return err
 		}
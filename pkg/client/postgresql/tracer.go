@@ -0,0 +1,88 @@
+package postgresql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Shurubtsov/lamoda-test-task/pkg/tracing"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer создаёт спаны для запросов, пачек запросов и COPY, выполняемых через pgx.
+type tracer struct {
+	database string
+}
+
+func (t *tracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = tracing.StartClient(ctx, spanName(data.SQL, t.database),
+		semconv.DBSystemPostgreSQL,
+		semconv.DBNamespace(t.database),
+		semconv.DBQueryText(data.SQL),
+	)
+	return ctx
+}
+
+func (t *tracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil {
+		tracing.Fail(span, data.Err)
+	}
+	span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	span.End()
+}
+
+func (t *tracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	ctx, _ = tracing.StartClient(ctx, "BATCH "+t.database,
+		semconv.DBSystemPostgreSQL,
+		semconv.DBNamespace(t.database),
+		attribute.Int("db.batch.size", data.Batch.Len()),
+	)
+	return ctx
+}
+
+// TraceBatchQuery отмечает выполнение запроса пачки событием спана пачки.
+func (t *tracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	attrs := []attribute.KeyValue{semconv.DBQueryText(data.SQL)}
+	if data.Err != nil {
+		attrs = append(attrs, attribute.String("error", data.Err.Error()))
+	}
+	trace.SpanFromContext(ctx).AddEvent("query", trace.WithAttributes(attrs...))
+}
+
+func (t *tracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil {
+		tracing.Fail(span, data.Err)
+	}
+	span.End()
+}
+
+func (t *tracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	ctx, _ = tracing.StartClient(ctx, "COPY "+data.TableName.Sanitize(),
+		semconv.DBSystemPostgreSQL,
+		semconv.DBNamespace(t.database),
+		semconv.DBCollectionName(data.TableName.Sanitize()),
+	)
+	return ctx
+}
+
+func (t *tracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil {
+		tracing.Fail(span, data.Err)
+	}
+	span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	span.End()
+}
+
+// spanName называет спан по первому слову запроса (SELECT, INSERT, WITH ...) и имени бд.
+func spanName(sql, database string) string {
+	operation, _, _ := strings.Cut(strings.TrimSpace(sql), " ")
+	if i := strings.IndexAny(operation, "\n\t("); i >= 0 {
+		operation = operation[:i]
+	}
+	return strings.ToUpper(operation) + " " + database
+}
@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -13,6 +14,7 @@ import (
 	"github.com/Shurubtsov/lamoda-test-task/internal/config"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/pkgerrors"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type logger struct {
@@ -29,6 +31,36 @@ func GetLogger() *logger {
 	return instance
 }
 
+type loggerKey struct{}
+
+// WithFields возвращает контекст с дочерним логгером, записи которого содержат fields
+// в дополнение к полям логгера из ctx.
+func WithFields(ctx context.Context, fields map[string]any) context.Context {
+	child := &logger{Logger: FromContext(ctx).With().Fields(fields).Logger()}
+	return context.WithValue(ctx, loggerKey{}, child)
+}
+
+// FromContext возвращает логгер запроса из ctx (или общий логгер, если его нет),
+// записи которого также содержат идентификаторы трассы и спана из ctx.
+func FromContext(ctx context.Context) *logger {
+	parent := instance
+	if l, ok := ctx.Value(loggerKey{}).(*logger); ok {
+		parent = l
+	}
+	return &logger{Logger: parent.With().Ctx(ctx).Logger()}
+}
+
+// traceHook добавляет в запись trace_id и span_id, если в контексте записи есть спан.
+type traceHook struct{}
+
+func (traceHook) Run(e *zerolog.Event, _ zerolog.Level, _ string) {
+	spanContext := trace.SpanContextFromContext(e.GetCtx())
+	if !spanContext.IsValid() {
+		return
+	}
+	e.Str("trace_id", spanContext.TraceID().String()).Str("span_id", spanContext.SpanID().String())
+}
+
 func init() {
 
 	cfg := config.GetConfig()
@@ -107,7 +139,7 @@ func init() {
 			return file + " | " + runtime.FuncForPC(pc).Name() + "():" + strconv.Itoa(line)
 		}
 
-		logg := zerolog.New(output).With().Timestamp().Caller().Logger()
+		logg := zerolog.New(output).With().Timestamp().Caller().Logger().Hook(traceHook{})
 		logg.Info().Msg("Getting logger")
 
 		instance = &logger{Logger: logg}
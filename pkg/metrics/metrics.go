@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace префикс всех метрик сервиса. Имена и метки метрик описаны в README,
+// при их изменении нужно обновить дашборды.
+const namespace = "inventory"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of handled HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of HTTP requests by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	httpInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "Number of HTTP requests being handled by route.",
+	}, []string{"route"})
+
+	itemOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "item_outcomes_total",
+		Help:      "Outcomes of products in reservation and exemption requests by status and reason.",
+	}, []string{"operation", "status", "reason"})
+
+	lockConflicts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lock_conflicts_total",
+		Help:      "Number of operations rejected because products were locked by another transaction.",
+	}, []string{"operation"})
+
+	batchSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "batch_size",
+		Help:      "Number of items processed in one batch by operation.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+	}, []string{"operation"})
+
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Duration of repository queries by query name.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"query"})
+)
+
+// Handler отдаёт метрики в формате Prometheus.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveRequest учитывает обработанный HTTP запрос.
+func ObserveRequest(method, route string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+// RequestStarted учитывает начатый запрос, возвращаемая функция вызывается по его завершении.
+func RequestStarted(route string) func() {
+	gauge := httpInFlight.WithLabelValues(route)
+	gauge.Inc()
+	return gauge.Dec
+}
+
+// ItemOutcome учитывает результат обработки товара, reason пустой для успешного результата.
+func ItemOutcome(operation, status, reason string) {
+	itemOutcomes.WithLabelValues(operation, status, reason).Inc()
+}
+
+// LockConflict учитывает операцию, отклонённую из-за блокировки товаров другой транзакцией.
+func LockConflict(operation string) {
+	lockConflicts.WithLabelValues(operation).Inc()
+}
+
+// ObserveBatch учитывает размер пачки.
+func ObserveBatch(operation string, size int) {
+	batchSize.WithLabelValues(operation).Observe(float64(size))
+}
+
+// ObserveQuery учитывает время выполнения запроса к бд, начатого в start.
+// Вызывается через defer: defer metrics.ObserveQuery("FindProduct", time.Now()).
+func ObserveQuery(query string, start time.Time) {
+	queryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+}
+
+// RegisterPool регистрирует метрики пула соединений, значения читаются из pgxpool.Stat при каждом сборе.
+func RegisterPool(pool interface{ Stat() *pgxpool.Stat }) error {
+	return prometheus.Register(&poolCollector{pool: pool})
+}
+
+type poolCollector struct {
+	pool interface{ Stat() *pgxpool.Stat }
+}
+
+var (
+	poolMaxConns          = poolDesc("max_conns", "Maximum size of the pool.")
+	poolTotalConns        = poolDesc("total_conns", "Total number of connections in the pool.")
+	poolAcquiredConns     = poolDesc("acquired_conns", "Number of connections currently acquired.")
+	poolIdleConns         = poolDesc("idle_conns", "Number of idle connections in the pool.")
+	poolConstructingConns = poolDesc("constructing_conns", "Number of connections being constructed.")
+	poolAcquires          = poolDesc("acquires_total", "Number of successful connection acquires.")
+	poolAcquireDuration   = poolDesc("acquire_duration_seconds_total", "Total time spent acquiring connections.")
+	poolEmptyAcquires     = poolDesc("empty_acquires_total", "Number of acquires that waited for a connection because the pool was empty.")
+	poolCanceledAcquires  = poolDesc("canceled_acquires_total", "Number of acquires canceled by context.")
+	poolNewConns          = poolDesc("new_conns_total", "Number of new connections opened.")
+	poolLifetimeDestroys  = poolDesc("max_lifetime_destroys_total", "Number of connections closed because of max lifetime.")
+	poolIdleDestroys      = poolDesc("max_idle_destroys_total", "Number of connections closed because of max idle time.")
+)
+
+func poolDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	gauge := func(desc *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v)
+	}
+	counter := func(desc *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, v)
+	}
+	gauge(poolMaxConns, float64(stat.MaxConns()))
+	gauge(poolTotalConns, float64(stat.TotalConns()))
+	gauge(poolAcquiredConns, float64(stat.AcquiredConns()))
+	gauge(poolIdleConns, float64(stat.IdleConns()))
+	gauge(poolConstructingConns, float64(stat.ConstructingConns()))
+	counter(poolAcquires, float64(stat.AcquireCount()))
+	counter(poolAcquireDuration, stat.AcquireDuration().Seconds())
+	counter(poolEmptyAcquires, float64(stat.EmptyAcquireCount()))
+	counter(poolCanceledAcquires, float64(stat.CanceledAcquireCount()))
+	counter(poolNewConns, float64(stat.NewConnsCount()))
+	counter(poolLifetimeDestroys, float64(stat.MaxLifetimeDestroyCount()))
+	counter(poolIdleDestroys, float64(stat.MaxIdleDestroyCount()))
+}
@@ -0,0 +1,114 @@
+// Package tracing настраивает OpenTelemetry трассировку сервиса: экспорт спанов в OTLP,
+// stdout или файл и распространение контекста по заголовку W3C traceparent.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceName = "inventory"
+	tracerName  = "github.com/Shurubtsov/lamoda-test-task"
+)
+
+var ErrUnknownExporter = errors.New("unknown trace exporter, expected none, stdout, file or otlp")
+
+// Init устанавливает глобальный провайдер трассировки с экспортом kind: none (спаны не записываются,
+// но контекст вызывающей стороны распространяется), stdout, file (JSON в path) или otlp (OTLP/HTTP
+// на endpoint, при пустом endpoint используются переменные OTEL_EXPORTER_OTLP_*).
+// ratio - доля записываемых трасс, если вызывающая сторона не передала решение в traceparent.
+// Возвращаемая функция отправляет накопленные спаны и останавливает экспорт.
+func Init(ctx context.Context, kind, path, endpoint string, ratio float64) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var (
+		exporter sdktrace.SpanExporter
+		closer   func() error
+		err      error
+	)
+	switch kind {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "file":
+		var file *os.File
+		file, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		closer = file.Close
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, ErrUnknownExporter
+	}
+	if err != nil {
+		if closer != nil {
+			closer()
+		}
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer())
+		}
+		return err
+	}, nil
+}
+
+// Start начинает спан name дочерним к спану из ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartServer начинает корневой спан входящего запроса, родитель берётся из заголовков traceparent/tracestate.
+func StartServer(ctx context.Context, carrier propagation.TextMapCarrier, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+}
+
+// StartClient начинает спан исходящего запроса.
+func StartClient(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// Fail отмечает спан ошибочным.
+func Fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}